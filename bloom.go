@@ -0,0 +1,78 @@
+package stream
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+func (p *pipeline) DistinctApprox(key Function, expectedN int, fpRate float64) Stream {
+	nilCheck(key)
+	if expectedN < 1 {
+		expectedN = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		panic("fpRate must be between 0 and 1")
+	}
+	t := &pipeline{
+		name:          "DistinctApprox",
+		previousStage: p,
+		sourceStage:   p.sourceStage,
+	}
+	t.forkStage = func(r *span) (func(nextStage *pipeline, v interface{}), func(nextStage *pipeline)) {
+		filter := r.share(t, func() interface{} {
+			return newBloomFilter(expectedN, fpRate)
+		}).(*bloomFilter)
+		return func(nextStage *pipeline, v interface{}) {
+			if filter.add(hash(key(v))) {
+				nextStage.do(nextStage.nextStage, v)
+			}
+		}, nil
+	}
+	return t
+}
+
+// bloomFilter is sized for n keys at false positive rate p with
+// m = -n ln p / ln² 2 bits and k = m/n ln 2 hashes, derived from one 64 bit
+// hash by double hashing. The bits are set atomically, so that the workers of
+// a parallel evaluation share the filter without locking.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    int
+}
+
+func newBloomFilter(n int, p float64) *bloomFilter {
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{bits: make([]uint64, (uint64(m)+63)/64), m: uint64(m), k: k}
+}
+
+// add sets the bits of x and reports whether any of them was not set yet,
+// that is whether x was surely not added before.
+func (b *bloomFilter) add(x uint64) bool {
+	h1, h2 := x&0xffffffff, x>>32|1
+	added := false
+	for i := 0; i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % b.m
+		if b.set(&b.bits[bit/64], 1<<(bit%64)) {
+			added = true
+		}
+	}
+	return added
+}
+
+// set ors mask into word and reports whether it changed word.
+func (b *bloomFilter) set(word *uint64, mask uint64) bool {
+	for {
+		old := atomic.LoadUint64(word)
+		if old&mask != 0 {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(word, old, old|mask) {
+			return true
+		}
+	}
+}
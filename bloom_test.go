@@ -0,0 +1,50 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDistinctApprox(t *testing.T) {
+	ints := make([]int, 100000)
+	for i := range ints {
+		ints[i] = i % 10000
+	}
+	identity := func(v interface{}) interface{} {
+		return v
+	}
+	for _, s := range []Stream{New(ints), Parallel(ints)} {
+		if count := s.DistinctApprox(identity, 10000, 0.01).Count(); count < 9800 || count > 10000 {
+			t.Errorf("count %d, want about 10000", count)
+		}
+	}
+	var first []int
+	New([]int{3, 1, 3, 2, 1}).DistinctApprox(identity, 10, 0.01).ToSlice(&first)
+	if !reflect.DeepEqual(first, []int{3, 1, 2}) {
+		t.Errorf("first %v", first)
+	}
+	generated := 0
+	var limited []int
+	Generate(func(yield func(v interface{}) bool) {
+		for i := 0; yield(i % 5); i++ {
+			generated++
+		}
+	}).DistinctApprox(identity, 100, 0.01).Limit(3).ToSlice(&limited)
+	if !reflect.DeepEqual(limited, []int{0, 1, 2}) || generated > 2 {
+		t.Errorf("limited %v after %d elements", limited, generated)
+	}
+}
+
+func TestDistinctApproxEvaluations(t *testing.T) {
+	identity := func(v interface{}) interface{} {
+		return v
+	}
+	for _, s := range []Stream{New([]int{1, 2, 3, 2}), Parallel([]int{1, 2, 3})} {
+		s = s.DistinctApprox(identity, 10, 0.01)
+		for i := 0; i < 2; i++ {
+			if count := s.Count(); count != 3 {
+				t.Errorf("evaluation %d: count %d, want 3", i, count)
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package stream
+
+import "time"
+
+// Clock tells the time and sleeps for the time based stages, tests inject a
+// fake one with WithClock.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct {
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (p *pipeline) WithClock(clock Clock) Stream {
+	nilCheck(clock)
+	t := p.settingStage("WithClock")
+	t.clock = clock
+	return t
+}
+
+// stageClock returns the clock of the last WithClock stage up to p, also
+// upstream of barriers, or the system clock.
+func (p *pipeline) stageClock() Clock {
+	var clock Clock = systemClock{}
+	found := false
+	p.walk(func(stage *pipeline) {
+		if stage.clock != nil && !found {
+			clock, found = stage.clock, true
+		}
+	})
+	return clock
+}
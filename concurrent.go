@@ -0,0 +1,120 @@
+package stream
+
+func (p *pipeline) MapConcurrent(n int, function Function) Stream {
+	if !p.ordered() {
+		return p.mapConcurrent("MapConcurrent", n, function, unorderedMap)
+	}
+	return p.mapConcurrent("MapConcurrent", n, function, orderedMap)
+}
+
+func (p *pipeline) MapConcurrentUnordered(n int, function Function) Stream {
+	return p.mapConcurrent("MapConcurrentUnordered", n, function, unorderedMap)
+}
+
+// mapConcurrent builds the stage, fork creates the do and end functions of
+// every range.
+func (p *pipeline) mapConcurrent(name string, n int, function Function,
+	fork func(n int, function Function) (func(nextStage *pipeline, v interface{}), func(nextStage *pipeline))) Stream {
+	nilCheck(function)
+	if n < 1 {
+		n = 1
+	}
+	return &pipeline{
+		name:          name,
+		previousStage: p,
+		sourceStage:   p.sourceStage,
+		forkStage: func(r *span) (func(nextStage *pipeline, v interface{}), func(nextStage *pipeline)) {
+			return fork(n, function)
+		},
+	}
+}
+
+// orderedMap keeps the results of the calls in flight in a queue and passes on
+// its head once it is ready.
+func orderedMap(n int, function Function) (func(nextStage *pipeline, v interface{}), func(nextStage *pipeline)) {
+	var pending []chan outcome
+	do := func(nextStage *pipeline, v interface{}) {
+		if len(pending) == n {
+			nextStage.do(nextStage.nextStage, (<-pending[0]).get())
+			pending = pending[1:]
+		}
+		result := make(chan outcome, 1)
+		go func() {
+			result <- call(function, v)
+		}()
+		pending = append(pending, result)
+		for len(pending) > 0 {
+			select {
+			case r := <-pending[0]:
+				nextStage.do(nextStage.nextStage, r.get())
+				pending = pending[1:]
+			default:
+				return
+			}
+		}
+	}
+	end := func(nextStage *pipeline) {
+		for _, result := range pending {
+			nextStage.do(nextStage.nextStage, (<-result).get())
+		}
+		pending = nil
+	}
+	return do, end
+}
+
+func unorderedMap(n int, function Function) (func(nextStage *pipeline, v interface{}), func(nextStage *pipeline)) {
+	results := make(chan outcome, n)
+	inFlight := 0
+	do := func(nextStage *pipeline, v interface{}) {
+		if inFlight == n {
+			inFlight--
+			nextStage.do(nextStage.nextStage, (<-results).get())
+		}
+		inFlight++
+		go func() {
+			results <- call(function, v)
+		}()
+		for {
+			select {
+			case r := <-results:
+				inFlight--
+				nextStage.do(nextStage.nextStage, r.get())
+			default:
+				return
+			}
+		}
+	}
+	end := func(nextStage *pipeline) {
+		for ; inFlight > 0; inFlight-- {
+			nextStage.do(nextStage.nextStage, (<-results).get())
+		}
+	}
+	return do, end
+}
+
+// outcome is what a call on another goroutine returned or the panic it
+// raised, get raises it again on the goroutine of the stage.
+type outcome struct {
+	v        interface{}
+	panicked bool
+	p        interface{}
+}
+
+func call(function Function, v interface{}) (o outcome) {
+	o.panicked = true
+	defer func() {
+		if o.panicked {
+			o.p = recover()
+		}
+	}()
+	o.v = function(v)
+	o.panicked = false
+	return o
+}
+
+func (o outcome) get() interface{} {
+	if o.panicked {
+		panic(o.p)
+	}
+	return o.v
+}
@@ -0,0 +1,95 @@
+package stream
+
+import (
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMapConcurrent(t *testing.T) {
+	ints := createInts(50)
+	var inFlight, maxInFlight int32
+	slow := func(v interface{}) interface{} {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(v.(int)%5) * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return v.(int) * 2
+	}
+	var want []int
+	New(ints).Map(func(v interface{}) interface{} {
+		return v.(int) * 2
+	}).ToSlice(&want)
+
+	var ordered []int
+	New(ints).MapConcurrent(4, slow).ToSlice(&ordered)
+	if !reflect.DeepEqual(ordered, want) {
+		t.Errorf("ordered %v, want %v", ordered, want)
+	}
+	if maxInFlight > 4 {
+		t.Errorf("%d calls in flight, want at most 4", maxInFlight)
+	}
+
+	var unordered []int
+	New(ints).MapConcurrentUnordered(4, slow).ToSlice(&unordered)
+	sort.Ints(unordered)
+	sort.Ints(want)
+	if !reflect.DeepEqual(unordered, want) {
+		t.Errorf("unordered %v, want %v", unordered, want)
+	}
+
+	it := New(ints).MapConcurrent(3, slow).Iterator()
+	count := 0
+	for it.HasNext() {
+		it.Next()
+		count++
+	}
+	if count != len(ints) {
+		t.Errorf("iterated %d, want %d", count, len(ints))
+	}
+
+	first := Parallel(ints).MapConcurrent(2, slow).FindFirst(func(v interface{}) bool {
+		return v.(int) > 10
+	})
+	if first != firstAbove(ordered, 10) {
+		t.Errorf("first %v, want %v", first, firstAbove(ordered, 10))
+	}
+}
+
+func firstAbove(ints []int, n int) interface{} {
+	for _, v := range ints {
+		if v > n {
+			return v
+		}
+	}
+	return nil
+}
+
+func TestMapConcurrentPanic(t *testing.T) {
+	boom := func(v interface{}) interface{} {
+		if v.(int) == 7 {
+			panic("boom")
+		}
+		return v
+	}
+	for _, s := range []Stream{
+		New(createInts(20)).MapConcurrent(3, boom),
+		New(createInts(20)).MapConcurrentUnordered(3, boom),
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != "boom" {
+					t.Errorf("recovered %v, want boom", r)
+				}
+			}()
+			s.Count()
+		}()
+	}
+}
@@ -0,0 +1,85 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Plan describes the stages of a pipeline from its source to the stage
+// Explain was called on.
+type Plan struct {
+	Stages []PlanStage
+}
+
+// PlanStage describes one stage of a Plan. Barrier stages evaluate everything
+// upstream of them before any downstream stage runs.
+type PlanStage struct {
+	Position int
+	Kind     string
+	Barrier  bool
+	Parallel bool
+}
+
+func (p *pipeline) Explain() *Plan {
+	var stages []*pipeline
+	p.walk(func(stage *pipeline) {
+		if !stage.setting {
+			stages = append(stages, stage)
+		}
+	})
+	plan := &Plan{Stages: make([]PlanStage, len(stages))}
+	for i := range stages {
+		stage := stages[len(stages)-1-i]
+		plan.Stages[i] = PlanStage{
+			Position: i,
+			Kind:     stage.name,
+			Barrier:  stage.upstream != nil,
+			Parallel: stage.sourceStage.parallel,
+		}
+	}
+	return plan
+}
+
+func (s PlanStage) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s", s.Position, s.Kind)
+	if s.Barrier {
+		b.WriteString(" barrier")
+	}
+	if s.Parallel {
+		b.WriteString(" parallel")
+	}
+	return b.String()
+}
+
+// String renders the plan as one line per stage.
+func (p *Plan) String() string {
+	var b strings.Builder
+	for _, stage := range p.Stages {
+		b.WriteString(stage.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Dot renders the plan as a Graphviz digraph. Barriers are drawn as boxes and
+// parallel stages in bold.
+func (p *Plan) Dot() string {
+	var b strings.Builder
+	b.WriteString("digraph pipeline {\n")
+	for _, stage := range p.Stages {
+		shape, style := "ellipse", "solid"
+		if stage.Barrier {
+			shape = "box"
+		}
+		if stage.Parallel {
+			style = "bold"
+		}
+		fmt.Fprintf(&b, "\ts%d [label=%q shape=%s style=%s];\n", stage.Position, stage.Kind, shape, style)
+		if stage.Position > 0 {
+			fmt.Fprintf(&b, "\ts%d -> s%d;\n", stage.Position-1, stage.Position)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,23 @@
+package stream
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	students := createStudents()
+	plan := Parallel(students).Filter(func(v interface{}) bool {
+		return v.(student).age > 20
+	}).Sorted(func(i, j interface{}) bool {
+		return i.(student).age < j.(student).age
+	}).Map(func(v interface{}) interface{} {
+		return v.(student).name
+	}).Explain()
+	fmt.Print(plan.Dot())
+
+	want := "0 Source parallel\n1 Filter parallel\n2 Sorted barrier parallel\n3 Map parallel\n"
+	if plan.String() != want {
+		t.Errorf("plan\n%s\nwant\n%s", plan, want)
+	}
+}
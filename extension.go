@@ -0,0 +1,97 @@
+package stream
+
+// Sink receives the elements a Stage passes on.
+type Sink interface {
+	Accept(v interface{})
+	// CancellationRequested reports whether the downstream stages need no
+	// more elements.
+	CancellationRequested() bool
+}
+
+// Stage is a custom intermediate operation added by Then. Every range of the
+// source gets its own Stage, so a Stage needs no locking.
+type Stage interface {
+	// Begin is called before the first element with the sink of the
+	// downstream stages.
+	Begin(downstream Sink)
+	Accept(v interface{})
+	// End is called after the last element, the stage may pass on the
+	// elements it held back.
+	End()
+	// CancellationRequested reports whether the stage needs no more
+	// elements, the source then stops.
+	CancellationRequested() bool
+}
+
+// Collector is a custom terminal operation evaluated by Collect. Every range
+// of the source gets its own Collector, merged in encounter order.
+type Collector interface {
+	Accept(v interface{})
+	// End is called after the last element of the range.
+	End()
+	CancellationRequested() bool
+	// Combine merges the collector of the following range into this one.
+	Combine(right Collector)
+}
+
+func (p *pipeline) Then(newStage func() Stage) Stream {
+	nilCheck(newStage)
+	return &pipeline{
+		name:          "Then",
+		previousStage: p,
+		sourceStage:   p.sourceStage,
+		newStage:      newStage,
+	}
+}
+
+func (p *pipeline) Collect(newCollector func() Collector) Collector {
+	nilCheck(newCollector)
+	return p.collect("Collect", func() sink {
+		return &collectorSink{newCollector()}
+	}).(*collectorSink).Collector
+}
+
+// begin begins stage in front of next and returns the do, end and cancelled
+// functions of its chain stage.
+func begin(stage Stage, next *pipeline) (func(nextStage *pipeline, v interface{}), func(nextStage *pipeline), func() bool) {
+	stage.Begin(&downstream{next: next})
+	do := func(nextStage *pipeline, v interface{}) {
+		stage.Accept(v)
+	}
+	end := func(nextStage *pipeline) {
+		stage.End()
+	}
+	cancelled := func() bool {
+		return stage.CancellationRequested() || next.cancelled()
+	}
+	return do, end, cancelled
+}
+
+// downstream is the Sink of the stages from next on.
+type downstream struct {
+	next *pipeline
+}
+
+func (d *downstream) Accept(v interface{}) {
+	d.next.do(d.next.nextStage, v)
+}
+func (d *downstream) CancellationRequested() bool {
+	return d.next.cancelled != nil && d.next.cancelled()
+}
+
+type collectorSink struct {
+	Collector
+}
+
+func (c *collectorSink) accept(v interface{}) {
+	c.Accept(v)
+}
+func (c *collectorSink) end() {
+	c.End()
+}
+func (c *collectorSink) cancellationRequested() bool {
+	return c.CancellationRequested()
+}
+func (c *collectorSink) combine(right sink) {
+	c.Combine(right.(*collectorSink).Collector)
+}
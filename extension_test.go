@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+)
+
+// pairs passes on the elements of its range two by two, and the odd one
+// alone on End.
+type pairs struct {
+	downstream Sink
+	held       interface{}
+}
+
+func (p *pairs) Begin(downstream Sink) {
+	p.downstream = downstream
+}
+func (p *pairs) Accept(v interface{}) {
+	if p.held == nil {
+		p.held = v
+		return
+	}
+	p.downstream.Accept([]interface{}{p.held, v})
+	p.held = nil
+}
+func (p *pairs) End() {
+	if p.held != nil {
+		p.downstream.Accept([]interface{}{p.held})
+	}
+}
+func (p *pairs) CancellationRequested() bool {
+	return p.downstream.CancellationRequested()
+}
+
+// takeWhile passes on the elements up to the first one failing predicate.
+type takeWhile struct {
+	downstream Sink
+	predicate  Predicate
+	done       bool
+}
+
+func (t *takeWhile) Begin(downstream Sink) {
+	t.downstream = downstream
+}
+func (t *takeWhile) Accept(v interface{}) {
+	if t.done = t.done || !t.predicate(v); !t.done {
+		t.downstream.Accept(v)
+	}
+}
+func (t *takeWhile) End() {
+}
+func (t *takeWhile) CancellationRequested() bool {
+	return t.done || t.downstream.CancellationRequested()
+}
+
+type sumCollector struct {
+	sum int
+}
+
+func (s *sumCollector) Accept(v interface{}) {
+	s.sum += v.(int)
+}
+func (s *sumCollector) End() {
+}
+func (s *sumCollector) CancellationRequested() bool {
+	return false
+}
+func (s *sumCollector) Combine(right Collector) {
+	s.sum += right.(*sumCollector).sum
+}
+
+func TestThen(t *testing.T) {
+	var paired [][]interface{}
+	New([]int{1, 2, 3, 4, 5}).Then(func() Stage {
+		return &pairs{}
+	}).ToSlice(&paired)
+	if !reflect.DeepEqual(paired, [][]interface{}{{1, 2}, {3, 4}, {5}}) {
+		t.Errorf("paired %v", paired)
+	}
+	count := Parallel(createInts(1000)).Then(func() Stage {
+		return &pairs{}
+	}).Map(func(v interface{}) interface{} {
+		return len(v.([]interface{}))
+	}).Reduce(func(t, u interface{}) interface{} {
+		return t.(int) + u.(int)
+	})
+	if count != 1000 {
+		t.Errorf("%d elements in pairs", count)
+	}
+	generated := 0
+	var taken []int
+	Generate(func(yield func(v interface{}) bool) {
+		for i := 0; yield(i); i++ {
+			generated++
+		}
+	}).Then(func() Stage {
+		return &takeWhile{predicate: func(v interface{}) bool {
+			return v.(int) < 3
+		}}
+	}).ToSlice(&taken)
+	if !reflect.DeepEqual(taken, []int{0, 1, 2}) || generated > 3 {
+		t.Errorf("taken %v after %d elements", taken, generated)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		sum := stream(createInts(1000), parallel).Collect(func() Collector {
+			return &sumCollector{}
+		}).(*sumCollector).sum
+		if sum != 999*1000/2 {
+			t.Errorf("parallel=%v: sum %d", parallel, sum)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package stream
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// SpillOptions configures SortedExternal.
+type SpillOptions struct {
+	// RunSize is the number of elements, not bytes, a worker sorts in memory
+	// before spilling them, defaults to 100000. Every worker of a parallel
+	// stream holds up to RunSize elements at once, so the memory budget is
+	// about RunSize times GOMAXPROCS times the size of an element.
+	RunSize int
+	// FanIn is the most runs merged at once, each keeping a file open,
+	// defaults to 64. More runs are first merged FanIn at a time into longer
+	// runs, in as many passes as needed.
+	FanIn int
+	// Dir holds the temporary files, defaults to os.TempDir.
+	Dir string
+	// Codec writes and reads the spilled elements, defaults to GobCodec.
+	Codec SpillCodec
+}
+
+// SpillCodec encodes elements to and decodes them from a spill file.
+type SpillCodec interface {
+	NewEncoder(w io.Writer) SpillEncoder
+	NewDecoder(r io.Reader) SpillDecoder
+}
+
+type SpillEncoder interface {
+	Encode(v interface{}) error
+}
+
+// SpillDecoder returns io.EOF once it has decoded every element.
+type SpillDecoder interface {
+	Decode() (interface{}, error)
+}
+
+// GobCodec spills elements with encoding/gob, element types other than the
+// basic ones must be registered with gob.Register.
+type GobCodec struct {
+}
+
+func (GobCodec) NewEncoder(w io.Writer) SpillEncoder {
+	return gobEncoder{gob.NewEncoder(w)}
+}
+
+func (GobCodec) NewDecoder(r io.Reader) SpillDecoder {
+	return gobDecoder{gob.NewDecoder(r)}
+}
+
+type gobEncoder struct {
+	e *gob.Encoder
+}
+
+func (g gobEncoder) Encode(v interface{}) error {
+	return g.e.Encode(&v)
+}
+
+type gobDecoder struct {
+	d *gob.Decoder
+}
+
+func (g gobDecoder) Decode() (interface{}, error) {
+	var v interface{}
+	err := g.d.Decode(&v)
+	return v, err
+}
+
+// SortedExternal feeds the downstream stages sequentially from the merge. The
+// temporary files are removed once the merge is done or stopped.
+func (p *pipeline) SortedExternal(comparator Comparator, opts SpillOptions) Stream {
+	nilCheck(comparator)
+	if opts.RunSize < 1 {
+		opts.RunSize = 100000
+	}
+	if opts.FanIn < 2 {
+		opts.FanIn = 64
+	}
+	if opts.Codec == nil {
+		opts.Codec = GobCodec{}
+	}
+	t := p.barrierSource("SortedExternal")
+	t.parallel = false
+	t.generate = func(down *evaluation, yield func(v interface{}) bool) error {
+		collected, err := p.collectFor(down, "SortedExternal", func() sink {
+			return &spillSink{comparator: comparator, opts: opts}
+		})
+		s := collected.(*spillSink)
+		defer s.remove()
+		if s.err == nil {
+			s.err = s.merge(yield)
+		}
+		if err != nil {
+			return err
+		}
+		return s.err
+	}
+	return t
+}
+
+// run is a sorted run, spilled to the closed file name or, for the last run
+// of a worker, kept in data.
+type run struct {
+	name string
+	data []interface{}
+}
+
+// spillSink spills sorted runs of its range, combine appends the runs of the
+// following range. files are all the temporary files it created.
+type spillSink struct {
+	comparator Comparator
+	opts       SpillOptions
+	buffer     []interface{}
+	runs       []*run
+	files      []string
+	err        error
+}
+
+func (s *spillSink) accept(v interface{}) {
+	if s.err != nil {
+		return
+	}
+	s.buffer = append(s.buffer, v)
+	if len(s.buffer) >= s.opts.RunSize {
+		s.err = s.spill()
+	}
+}
+
+func (s *spillSink) spill() error {
+	sort.Stable(&sortData{data: s.buffer, comparator: s.comparator})
+	r, err := s.write(each(s.buffer))
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, r)
+	s.buffer = s.buffer[:0]
+	return nil
+}
+
+// write writes the elements of source to a new run file, which is closed
+// once written.
+func (s *spillSink) write(source func(yield func(v interface{}) bool)) (r *run, err error) {
+	file, err := ioutil.TempFile(s.opts.Dir, "stream-sort-")
+	if err != nil {
+		return nil, err
+	}
+	s.files = append(s.files, file.Name())
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	w := bufio.NewWriter(file)
+	encoder := s.opts.Codec.NewEncoder(w)
+	source(func(v interface{}) bool {
+		err = encoder.Encode(v)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return &run{name: file.Name()}, nil
+}
+
+func (s *spillSink) end() {
+	if s.err == nil && len(s.buffer) > 0 {
+		sort.Stable(&sortData{data: s.buffer, comparator: s.comparator})
+		s.runs = append(s.runs, &run{data: s.buffer})
+		s.buffer = nil
+	}
+}
+func (s *spillSink) cancellationRequested() bool {
+	return s.err != nil
+}
+func (s *spillSink) combine(right sink) {
+	r := right.(*spillSink)
+	s.runs = append(s.runs, r.runs...)
+	s.files = append(s.files, r.files...)
+	if s.err == nil {
+		s.err = r.err
+	}
+}
+
+func (s *spillSink) remove() {
+	for _, name := range s.files {
+		os.Remove(name)
+	}
+}
+
+// runReader reads the elements of one run, in memory or from its file.
+type runReader struct {
+	index   int
+	data    []interface{}
+	decoder SpillDecoder
+	head    interface{}
+}
+
+func (r *runReader) next() (bool, error) {
+	if r.decoder == nil {
+		if len(r.data) == 0 {
+			return false, nil
+		}
+		r.head, r.data = r.data[0], r.data[1:]
+		return true, nil
+	}
+	v, err := r.decoder.Decode()
+	if err == io.EOF {
+		return false, nil
+	}
+	r.head = v
+	return err == nil, err
+}
+
+// mergeHeap orders the run readers by their heads, equal heads by run, so
+// that the merge is stable.
+type mergeHeap struct {
+	comparator Comparator
+	readers    []*runReader
+}
+
+func (m *mergeHeap) Len() int {
+	return len(m.readers)
+}
+func (m *mergeHeap) Swap(i, j int) {
+	m.readers[i], m.readers[j] = m.readers[j], m.readers[i]
+}
+func (m *mergeHeap) Less(i, j int) bool {
+	a, b := m.readers[i], m.readers[j]
+	if m.comparator(a.head, b.head) {
+		return true
+	}
+	if m.comparator(b.head, a.head) {
+		return false
+	}
+	return a.index < b.index
+}
+func (m *mergeHeap) Push(x interface{}) {
+	m.readers = append(m.readers, x.(*runReader))
+}
+func (m *mergeHeap) Pop() interface{} {
+	r := m.readers[len(m.readers)-1]
+	m.readers = m.readers[:len(m.readers)-1]
+	return r
+}
+
+// merge yields the elements of all runs in order until yield returns false,
+// first merging every FanIn consecutive runs into one until at most FanIn are
+// left. Merging consecutive runs keeps the sort stable.
+func (s *spillSink) merge(yield func(v interface{}) bool) error {
+	for len(s.runs) > s.opts.FanIn {
+		var merged []*run
+		for start := 0; start < len(s.runs); start += s.opts.FanIn {
+			group := s.runs[start:]
+			if len(group) > s.opts.FanIn {
+				group = group[:s.opts.FanIn]
+			}
+			if len(group) == 1 {
+				merged = append(merged, group[0])
+				continue
+			}
+			var mergeErr error
+			r, err := s.write(func(yield func(v interface{}) bool) {
+				mergeErr = s.mergeRuns(group, yield)
+			})
+			if err == nil {
+				err = mergeErr
+			}
+			if err != nil {
+				return err
+			}
+			for _, done := range group {
+				if done.name != "" {
+					os.Remove(done.name)
+				}
+			}
+			merged = append(merged, r)
+		}
+		s.runs = merged
+	}
+	return s.mergeRuns(s.runs, yield)
+}
+
+// mergeRuns yields the elements of runs in order until yield returns false,
+// with the files of runs open.
+func (s *spillSink) mergeRuns(runs []*run, yield func(v interface{}) bool) error {
+	m := &mergeHeap{comparator: s.comparator}
+	for i, r := range runs {
+		reader := &runReader{index: i, data: r.data}
+		if r.name != "" {
+			file, err := os.Open(r.name)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			reader.decoder = s.opts.Codec.NewDecoder(bufio.NewReader(file))
+		}
+		ok, err := reader.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			m.readers = append(m.readers, reader)
+		}
+	}
+	heap.Init(m)
+	for m.Len() > 0 {
+		reader := m.readers[0]
+		if !yield(reader.head) {
+			return nil
+		}
+		ok, err := reader.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(m, 0)
+		} else {
+			heap.Pop(m)
+		}
+	}
+	return nil
+}
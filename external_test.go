@@ -0,0 +1,68 @@
+package stream
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSortedExternal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stream-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	assertEmpty := func() {
+		files, _ := ioutil.ReadDir(dir)
+		if len(files) != 0 {
+			t.Errorf("%d temporary files left", len(files))
+		}
+	}
+
+	ints := createInts(1000)
+	less := func(i, j interface{}) bool {
+		return i.(int)%100 < j.(int)%100
+	}
+	var want []int
+	New(ints).Sorted(less).ToSlice(&want)
+	opts := SpillOptions{RunSize: 64, Dir: dir}
+	for _, fanIn := range []int{0, 2, 3} {
+		opts.FanIn = fanIn
+		for _, parallel := range []bool{false, true} {
+			var res []int
+			s := stream(ints, parallel).SortedExternal(less, opts)
+			s.ToSlice(&res)
+			if s.Err() != nil {
+				t.Fatal(s.Err())
+			}
+			if !reflect.DeepEqual(res, want) {
+				t.Errorf("fanIn=%d parallel=%v: sorted %v, want %v", fanIn, parallel, res, want)
+			}
+			assertEmpty()
+		}
+	}
+
+	count := 0
+	New(ints).SortedExternal(less, opts).All()(func(v interface{}) bool {
+		count++
+		return count < 10
+	})
+	if count != 10 {
+		t.Errorf("yielded %d, want 10", count)
+	}
+	assertEmpty()
+
+	it := New(ints).SortedExternal(less, opts).Iterator()
+	it.Next()
+	it.Close()
+	assertEmpty()
+
+	s := New(createStudents()).SortedExternal(func(i, j interface{}) bool {
+		return i.(student).age < j.(student).age
+	}, SpillOptions{RunSize: 2, Dir: dir})
+	if s.Count(); s.Err() == nil {
+		t.Error("spilling unexported fields succeeded")
+	}
+	assertEmpty()
+}
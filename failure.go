@@ -0,0 +1,134 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// failure keeps the first error of an evaluation, once set the workers stop
+// pushing elements.
+type failure struct {
+	lock    sync.Mutex
+	err     error
+	stopped int32
+}
+
+func (f *failure) fail(err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.err == nil {
+		f.err = err
+		atomic.StoreInt32(&f.stopped, 1)
+	}
+}
+
+func (f *failure) failed() bool {
+	return atomic.LoadInt32(&f.stopped) == 1
+}
+
+func (f *failure) error() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.err
+}
+
+// evaluation is the state of one evaluation of a stream, shared by all the
+// ranges of its source.
+type evaluation struct {
+	failure
+	lock   sync.Mutex
+	shared map[*pipeline]interface{}
+	// observers are the Observe stages the evaluation reports to, labels is
+	// the Label stage labeling it and names the names of its stages.
+	observers []*pipeline
+	labels    *pipeline
+	names     map[*pipeline]string
+}
+
+// newEvaluation returns an evaluation of the stages up to terminal, set up by
+// the setting stages upstream of terminal and by down, the evaluation reading
+// the barrier terminal fills, if any.
+func newEvaluation(terminal *pipeline, down *evaluation) *evaluation {
+	ev := &evaluation{}
+	// a Named stage names the stage before it, the last Label applies
+	name := ""
+	terminal.walk(func(stage *pipeline) {
+		switch {
+		case stage.observer != nil:
+			ev.observers = append([]*pipeline{stage}, ev.observers...)
+		case stage.labelContext != nil && ev.labels == nil:
+			ev.labels = stage
+		case stage.label != "" && name == "":
+			name = stage.label
+		case !stage.setting && name != "":
+			if ev.names == nil {
+				ev.names = make(map[*pipeline]string)
+			}
+			ev.names[stage], name = name, ""
+		}
+	})
+	if down != nil {
+		if ev.labels == nil {
+			ev.labels = down.labels
+		}
+		for _, stage := range down.observers {
+			if !ev.observedBy(stage) {
+				ev.observers = append(ev.observers, stage)
+			}
+		}
+	}
+	return ev
+}
+
+func (e *evaluation) observedBy(stage *pipeline) bool {
+	for _, s := range e.observers {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// share returns the state stage keeps for the whole evaluation, created by
+// create for the first range asking for it.
+func (e *evaluation) share(stage *pipeline, create func() interface{}) interface{} {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	state, ok := e.shared[stage]
+	if !ok {
+		if e.shared == nil {
+			e.shared = make(map[*pipeline]interface{})
+		}
+		state = create()
+		e.shared[stage] = state
+	}
+	return state
+}
+
+// span is the range of the source a private chain evaluates, of length
+// elements starting at offset. index is the position in the source of the
+// element the chain is pushing.
+type span struct {
+	*evaluation
+	offset int
+	length int
+	index  int
+}
+
+// lastError is the error of the last evaluation of a stream.
+type lastError struct {
+	lock sync.Mutex
+	err  error
+}
+
+func (p *pipeline) setErr(err error) {
+	p.lastErr.lock.Lock()
+	defer p.lastErr.lock.Unlock()
+	p.lastErr.err = err
+}
+
+func (p *pipeline) Err() error {
+	p.lastErr.lock.Lock()
+	defer p.lastErr.lock.Unlock()
+	return p.lastErr.err
+}
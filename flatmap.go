@@ -0,0 +1,78 @@
+package stream
+
+import "reflect"
+
+// KeyValue is an element of a map expanded by FlatMap or of a stream created
+// by FromSeq2.
+type KeyValue struct {
+	Key, Value interface{}
+}
+
+// expand passes the elements of out to yield until it returns false. out is
+// a slice, an array, a map of which it passes KeyValue pairs, a channel it
+// reads until closed, a Stream, anything with the HasNext and Next methods of
+// an Iterator, closed when left early if it has Close, or a generator: a
+// func(yield func(v T) bool) of any element type T, such as an iter.Seq.
+func expand(out interface{}, yield func(v interface{}) bool) {
+	switch o := out.(type) {
+	case nil:
+		return
+	case Stream:
+		o.All()(yield)
+		return
+	case puller:
+		for o.HasNext() {
+			if !yield(o.Next()) {
+				if c, ok := o.(interface{ Close() }); ok {
+					c.Close()
+				}
+				return
+			}
+		}
+		return
+	case func(yield func(v interface{}) bool):
+		o(yield)
+		return
+	}
+	v := reflect.ValueOf(out)
+	switch v.Kind() {
+	case reflect.Map:
+		for it := v.MapRange(); it.Next(); {
+			if !yield(KeyValue{Key: it.Key().Interface(), Value: it.Value().Interface()}) {
+				return
+			}
+		}
+	case reflect.Chan:
+		for {
+			e, ok := v.Recv()
+			if !ok || !yield(e.Interface()) {
+				return
+			}
+		}
+	case reflect.Func:
+		if !isGenerator(v.Type()) {
+			panic("function must be a generator")
+		}
+		yieldType := v.Type().In(0)
+		v.Call([]reflect.Value{reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(yield(args[0].Interface())).Convert(yieldType.Out(0))}
+		})})
+	default:
+		yieldElements(out, yield)
+	}
+}
+
+// isGenerator reports whether t is a func(yield func(v T) bool).
+func isGenerator(t reflect.Type) bool {
+	if t.NumIn() != 1 || t.NumOut() != 0 || t.In(0).Kind() != reflect.Func {
+		return false
+	}
+	yield := t.In(0)
+	return yield.NumIn() == 1 && yield.NumOut() == 1 && yield.Out(0).Kind() == reflect.Bool
+}
+
+// puller is an Iterator that may not have a Close method.
+type puller interface {
+	HasNext() bool
+	Next() interface{}
+}
@@ -0,0 +1,120 @@
+package stream
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+func TestFlatMapKinds(t *testing.T) {
+	expansions := map[string]func(n int) interface{}{
+		"slice": func(n int) interface{} {
+			return []int{n, n}
+		},
+		"array": func(n int) interface{} {
+			return [2]int{n, n}
+		},
+		"stream": func(n int) interface{} {
+			return New([]int{n, n})
+		},
+		"iterator": func(n int) interface{} {
+			return New([]int{n, n}).Iterator()
+		},
+		"channel": func(n int) interface{} {
+			ch := make(chan int, 2)
+			ch <- n
+			ch <- n
+			close(ch)
+			return ch
+		},
+		"generator": func(n int) interface{} {
+			return func(yield func(v interface{}) bool) {
+				_ = yield(n) && yield(n)
+			}
+		},
+		"typed generator": func(n int) interface{} {
+			return func(yield func(v int) bool) {
+				_ = yield(n) && yield(n)
+			}
+		},
+	}
+	for name, expansion := range expansions {
+		for _, parallel := range []bool{false, true} {
+			var doubled []int
+			stream([]int{1, 2, 3}, parallel).FlatMap(func(v interface{}) interface{} {
+				return expansion(v.(int))
+			}).ToSlice(&doubled)
+			if !reflect.DeepEqual(doubled, []int{1, 1, 2, 2, 3, 3}) {
+				t.Errorf("%s parallel=%v: %v", name, parallel, doubled)
+			}
+		}
+	}
+	var keys []string
+	New([]map[string]int{{"a": 1, "b": 2}, {"c": 3}}).FlatMap(func(v interface{}) interface{} {
+		return v
+	}).Map(func(v interface{}) interface{} {
+		return v.(KeyValue).Key
+	}).ToSlice(&keys)
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"a", "b", "c"}) {
+		t.Errorf("keys %v", keys)
+	}
+}
+
+func TestFlatMapStopsEarly(t *testing.T) {
+	generated := 0
+	naturals := func(v interface{}) interface{} {
+		return Generate(func(yield func(v interface{}) bool) {
+			for i := 0; yield(i); i++ {
+				generated++
+			}
+		})
+	}
+	var limited []int
+	New([]int{1, 2}).FlatMap(naturals).Limit(3).ToSlice(&limited)
+	if !reflect.DeepEqual(limited, []int{0, 1, 2}) || generated > 3 {
+		t.Errorf("limited %v after %d elements", limited, generated)
+	}
+	ch := make(chan int, 100)
+	for i := 0; i < 100; i++ {
+		ch <- i
+	}
+	close(ch)
+	first := New([]int{1}).FlatMap(func(v interface{}) interface{} {
+		return ch
+	}).FindFirst(func(v interface{}) bool {
+		return v.(int) >= 10
+	})
+	if first != 10 || len(ch) != 89 {
+		t.Errorf("first %v, %d left in channel", first, len(ch))
+	}
+}
+
+func TestFlatMapDoesNotCopy(t *testing.T) {
+	inner := make([]int, 1000)
+	for _, parallel := range []bool{false, true} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		count := stream(createInts(1000), parallel).FlatMap(func(v interface{}) interface{} {
+			return inner
+		}).Count()
+		runtime.ReadMemStats(&after)
+		if count != 1000000 {
+			t.Errorf("parallel=%v: count %d", parallel, count)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("parallel=%v: %d bytes allocated", parallel, allocated)
+		}
+	}
+}
+
+func BenchmarkFlatMap(b *testing.B) {
+	ints := createInts(10000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Parallel(ints).FlatMap(func(v interface{}) interface{} {
+			return []int{v.(int), v.(int) + 1, v.(int) + 2}
+		}).Count()
+	}
+}
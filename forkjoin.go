@@ -0,0 +1,198 @@
+package stream
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ForkJoinOp splits the source into ranges, runs the stage chain on each
+// range and merges the per-range sinks of the terminal stage. The terminal
+// stage keeps the merged sink and the error of the evaluation.
+type ForkJoinOp struct {
+}
+
+func (f ForkJoinOp) EvaluateParallel(terminal *pipeline) {
+	start := time.Now()
+	sourceStage := terminal.sourceStage
+	ev := newEvaluation(terminal, terminal.downstream)
+	data := sourceStage.elements(ev)
+	grain := len(data) / (4 * runtime.GOMAXPROCS(0))
+	if grain < 1 {
+		grain = 1
+	}
+	terminal.result = observe(ev, terminal, f.fork(ev, terminal, data, 0, grain), start)
+	terminal.setErr(ev.error())
+}
+
+func (f ForkJoinOp) EvaluateSequential(terminal *pipeline) {
+	start := time.Now()
+	sourceStage := terminal.sourceStage
+	ev := newEvaluation(terminal, terminal.downstream)
+	terminal.result = observe(ev, terminal, f.leaf(ev, terminal, sourceStage.source(ev), 0, -1), start)
+	terminal.setErr(ev.error())
+}
+
+// fork evaluates data, the range of the source starting at offset.
+func (f ForkJoinOp) fork(ev *evaluation, terminal *pipeline, data []interface{}, offset, grain int) sink {
+	if len(data) <= grain {
+		return f.leaf(ev, terminal, each(data), offset, len(data))
+	}
+	mid := len(data) / 2
+	var left sink
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		left = f.fork(ev, terminal, data[:mid], offset, grain)
+	}()
+	right := f.fork(ev, terminal, data[mid:], offset+mid, grain)
+	waitGroup.Wait()
+	left.combine(right)
+	return left
+}
+
+// leaf evaluates the range of the source starting at offset, length is -1 for
+// the whole source of a sequential evaluation.
+func (f ForkJoinOp) leaf(ev *evaluation, terminal *pipeline, source func(yield func(v interface{}) bool), offset, length int) sink {
+	sourceStage := terminal.sourceStage
+	r := &span{evaluation: ev, offset: offset, length: length, index: offset}
+	s := terminal.makeSink()
+	if o, ok := s.(spanSink); ok {
+		o.setSpan(r)
+	}
+	if len(ev.observers) > 0 {
+		s = &observedSink{sink: s}
+	}
+	headStage := chain(sourceStage, terminal, s, r)
+	ev.withLabels(func() {
+		source(func(v interface{}) bool {
+			if headStage.cancelled() {
+				return false
+			}
+			headStage.do(headStage.nextStage, v)
+			r.index++
+			return !headStage.cancelled()
+		})
+		finish(headStage)
+	})
+	s.end()
+	return s
+}
+
+// chain copies the stages between sourceStage and terminal into a private
+// chain whose last stage feeds s, so that every range can run concurrently.
+// r is the range of the chain.
+func chain(sourceStage, terminal *pipeline, s sink, r *span) *pipeline {
+	stages := segment(sourceStage, terminal)
+	observed, _ := s.(*observedSink)
+	if observed != nil {
+		observed.counters = make([]stageCounter, len(stages)+1)
+		observed.running = -1
+	}
+	labels := r.stageLabels(append(stages, terminal))
+	tail := &pipeline{
+		do: func(nextStage *pipeline, v interface{}) {
+			s.accept(v)
+		},
+		cancelled: func() bool {
+			return s.cancellationRequested() || r.failed()
+		},
+	}
+	if labels != nil {
+		tail.do = labeled(labels, len(stages), tail.do)
+	}
+	headStage := tail
+	for i := len(stages) - 1; i >= 0; i-- {
+		do, end, cancelled := stages[i].do, (func(nextStage *pipeline))(nil), headStage.cancelled
+		if stages[i].forkStage != nil {
+			do, end = stages[i].forkStage(r)
+		}
+		if stages[i].newStage != nil {
+			do, end, cancelled = begin(stages[i].newStage(), headStage)
+		}
+		if observed != nil {
+			do, end = observed.wrap(i, do), observed.wrapEnd(i, end)
+		}
+		if labels != nil {
+			do = labeled(labels, i, do)
+		}
+		headStage = &pipeline{do: do, end: end, nextStage: headStage, cancelled: cancelled}
+	}
+	return headStage
+}
+
+// finish ends the stages of a chain in order, so that a stage holding back
+// elements passes them on before the stages downstream of it end.
+func finish(headStage *pipeline) {
+	for stage := headStage; stage != nil; stage = stage.nextStage {
+		if stage.end != nil {
+			stage.end(stage.nextStage)
+		}
+	}
+}
+
+// segment returns the stages between sourceStage and terminal, walking back
+// from terminal, without the setting stages.
+func segment(sourceStage, terminal *pipeline) []*pipeline {
+	n := 0
+	for stage := terminal.previousStage; stage != sourceStage; stage = stage.previousStage {
+		if !stage.setting {
+			n++
+		}
+	}
+	stages := make([]*pipeline, n)
+	for stage := terminal.previousStage; stage != sourceStage; stage = stage.previousStage {
+		if !stage.setting {
+			n--
+			stages[n] = stage
+		}
+	}
+	return stages
+}
+
+// walk visits p and the stages upstream of it, nearest first, going on from
+// the source stage of a barrier with the stage the barrier evaluates.
+func (p *pipeline) walk(visit func(stage *pipeline)) {
+	for stage := p; stage != nil; {
+		visit(stage)
+		if stage.previousStage != nil {
+			stage = stage.previousStage
+		} else {
+			stage = stage.upstream
+		}
+	}
+}
+
+func (p *pipeline) makeSink() sink {
+	if p.newSink != nil {
+		return p.newSink()
+	}
+	return &doSink{do: p.do}
+}
+
+// collect evaluates the stages up to p into sinks created by newSink and
+// returns the merged sink.
+func (p *pipeline) collect(name string, newSink func() sink) sink {
+	s, _ := p.collectErr(name, newSink)
+	return s
+}
+
+// collectErr is collect also returning the error of the evaluation.
+func (p *pipeline) collectErr(name string, newSink func() sink) (sink, error) {
+	return p.collectFor(nil, name, newSink)
+}
+
+// collectFor is collectErr for down, the evaluation reading what it collects,
+// whose settings the evaluation inherits.
+func (p *pipeline) collectFor(down *evaluation, name string, newSink func() sink) (sink, error) {
+	t := &pipeline{
+		name:          name,
+		previousStage: p,
+		sourceStage:   p.sourceStage,
+		newSink:       newSink,
+		downstream:    down,
+	}
+	t.evaluate(ForkJoinOp{})
+	return t.result, t.Err()
+}
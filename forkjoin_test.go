@@ -0,0 +1,160 @@
+package stream
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func createInts(n int) []int {
+	ints := make([]int, n)
+	for i := range ints {
+		ints[i] = (i * 7919) % n
+	}
+	return ints
+}
+
+func TestForkJoin(t *testing.T) {
+	ints := createInts(1000)
+	for _, parallel := range []bool{false, true} {
+		s := func() Stream {
+			return stream(ints, parallel).Filter(func(v interface{}) bool {
+				return v.(int)%3 != 0
+			})
+		}
+		var sorted []int
+		s().Sorted(func(i, j interface{}) bool {
+			return i.(int) < j.(int)
+		}).Limit(5).ToSlice(&sorted)
+		if !reflect.DeepEqual(sorted, []int{1, 2, 4, 5, 7}) {
+			t.Errorf("parallel=%v: sorted %v", parallel, sorted)
+		}
+		var limited []int
+		s().Skip(2).Limit(3).ToSlice(&limited)
+		if !reflect.DeepEqual(limited, []int{nthKept(ints, 2), nthKept(ints, 3), nthKept(ints, 4)}) {
+			t.Errorf("parallel=%v: limited %v", parallel, limited)
+		}
+		if count := s().Count(); count != 666 {
+			t.Errorf("parallel=%v: count %d", parallel, count)
+		}
+		distinct := s().Map(func(v interface{}) interface{} {
+			return v.(int) % 10
+		}).Distinct(func(i, j interface{}) bool {
+			return i == j
+		}).Count()
+		if distinct != 10 {
+			t.Errorf("parallel=%v: distinct %d", parallel, distinct)
+		}
+		first := s().FindFirst(func(v interface{}) bool {
+			return v.(int) > 500
+		})
+		if first != firstKeptAbove500(ints) {
+			t.Errorf("parallel=%v: first %v", parallel, first)
+		}
+		group := s().Group(func(v interface{}) interface{} {
+			return v.(int) % 2
+		})
+		if len(group[0])+len(group[1]) != 666 {
+			t.Errorf("parallel=%v: group %d+%d", parallel, len(group[0]), len(group[1]))
+		}
+	}
+}
+
+// nthKept returns the i-th element of ints kept by the filter of TestForkJoin.
+func nthKept(ints []int, i int) int {
+	for _, v := range ints {
+		if v%3 != 0 {
+			if i == 0 {
+				return v
+			}
+			i--
+		}
+	}
+	return -1
+}
+
+func firstKeptAbove500(ints []int) interface{} {
+	for _, v := range ints {
+		if v%3 != 0 && v > 500 {
+			return v
+		}
+	}
+	return nil
+}
+
+func TestForEachOp(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		p := stream(createInts(1000), parallel).Filter(func(v interface{}) bool {
+			return v.(int)%2 == 0
+		}).(*pipeline)
+		var sum int64
+		terminal := &pipeline{
+			previousStage: p,
+			sourceStage:   p.sourceStage,
+			do: func(nextStage *pipeline, v interface{}) {
+				atomic.AddInt64(&sum, int64(v.(int)))
+			},
+		}
+		terminal.evaluate(ForEachOp{})
+		if sum != 249500 {
+			t.Errorf("parallel=%v: sum %d, want 249500", parallel, sum)
+		}
+	}
+}
+
+func benchmarkEvaluate(b *testing.B, op TerminalOp) {
+	p := Parallel(createInts(100000)).Map(func(v interface{}) interface{} {
+		return v.(int) * 2
+	}).Filter(func(v interface{}) bool {
+		return v.(int)%3 == 0
+	}).(*pipeline)
+	var sum int64
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := &pipeline{
+			previousStage: p,
+			sourceStage:   p.sourceStage,
+			do: func(nextStage *pipeline, v interface{}) {
+				atomic.AddInt64(&sum, int64(v.(int)))
+			},
+		}
+		t.evaluate(op)
+	}
+}
+
+func BenchmarkForEachOp(b *testing.B) {
+	benchmarkEvaluate(b, ForEachOp{})
+}
+
+func BenchmarkForkJoinOp(b *testing.B) {
+	benchmarkEvaluate(b, ForkJoinOp{})
+}
+
+func BenchmarkForEachOpReduce(b *testing.B) {
+	ints := createInts(100000)
+	for i := 0; i < b.N; i++ {
+		var sum int
+		var lock sync.Mutex
+		s := Parallel(ints).(*pipeline)
+		t := &pipeline{
+			previousStage: s,
+			sourceStage:   s,
+			do: func(nextStage *pipeline, v interface{}) {
+				lock.Lock()
+				defer lock.Unlock()
+				sum += v.(int)
+			},
+		}
+		t.evaluate(ForEachOp{})
+	}
+}
+
+func BenchmarkForkJoinOpReduce(b *testing.B) {
+	ints := createInts(100000)
+	for i := 0; i < b.N; i++ {
+		Parallel(ints).Reduce(func(t, u interface{}) interface{} {
+			return t.(int) + u.(int)
+		})
+	}
+}
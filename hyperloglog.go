@@ -0,0 +1,121 @@
+package stream
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+func (p *pipeline) ApproxCountDistinct(key Function, precision int) int {
+	nilCheck(key)
+	if precision < 4 || precision > 18 {
+		panic("precision must be between 4 and 18")
+	}
+	return p.collect("ApproxCountDistinct", func() sink {
+		return &hyperLogLogSink{key: key, registers: make([]uint8, 1<<uint(precision)), precision: uint(precision)}
+	}).(*hyperLogLogSink).estimate()
+}
+
+// hyperLogLogSink keeps in each of its 2^precision registers the longest run
+// of leading zeros seen in the hashes of the keys routed to it.
+type hyperLogLogSink struct {
+	key       Function
+	registers []uint8
+	precision uint
+}
+
+func (h *hyperLogLogSink) accept(v interface{}) {
+	x := hash(h.key(v))
+	i := x >> (64 - h.precision)
+	rank := uint8(bits.LeadingZeros64(x<<h.precision|1<<(h.precision-1)) + 1)
+	if rank > h.registers[i] {
+		h.registers[i] = rank
+	}
+}
+func (h *hyperLogLogSink) end() {
+}
+func (h *hyperLogLogSink) cancellationRequested() bool {
+	return false
+}
+func (h *hyperLogLogSink) combine(right sink) {
+	for i, rank := range right.(*hyperLogLogSink).registers {
+		if rank > h.registers[i] {
+			h.registers[i] = rank
+		}
+	}
+}
+
+// estimate is the harmonic mean estimate of HyperLogLog, falling back to
+// linear counting of the empty registers for small cardinalities.
+func (h *hyperLogLogSink) estimate() int {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, rank := range h.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+	var alpha float64
+	switch len(h.registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+	e := alpha * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int(e + 0.5)
+}
+
+// hash returns a well mixed 64 bit hash of a key, keys of other types than
+// the basic ones are hashed by their Go syntax representation.
+func hash(key interface{}) uint64 {
+	var x uint64
+	switch k := key.(type) {
+	case int:
+		x = uint64(k)
+	case int32:
+		x = uint64(k)
+	case int64:
+		x = uint64(k)
+	case uint:
+		x = uint64(k)
+	case uint32:
+		x = uint64(k)
+	case uint64:
+		x = k
+	case float64:
+		x = math.Float64bits(k)
+	case bool:
+		if k {
+			x = 1
+		}
+	case string:
+		h := fnv.New64a()
+		h.Write([]byte(k))
+		x = h.Sum64()
+	case []byte:
+		h := fnv.New64a()
+		h.Write(k)
+		x = h.Sum64()
+	default:
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%#v", k)
+		x = h.Sum64()
+	}
+	// the finalizer of MurmurHash3
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,36 @@
+package stream
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestApproxCountDistinct(t *testing.T) {
+	ints := make([]int, 200000)
+	for i := range ints {
+		ints[i] = i % 50000
+	}
+	for _, s := range []func() Stream{
+		func() Stream { return New(ints) },
+		func() Stream { return Parallel(ints) },
+	} {
+		count := s().ApproxCountDistinct(func(v interface{}) interface{} {
+			return v
+		}, 14)
+		if math.Abs(float64(count)-50000)/50000 > 0.03 {
+			t.Errorf("count %d, want about 50000", count)
+		}
+		users := s().ApproxCountDistinct(func(v interface{}) interface{} {
+			return fmt.Sprintf("user-%d", v.(int)%100)
+		}, 12)
+		if users < 98 || users > 102 {
+			t.Errorf("users %d, want about 100", users)
+		}
+	}
+	if count := New([]int{}).ApproxCountDistinct(func(v interface{}) interface{} {
+		return v
+	}, 4); count != 0 {
+		t.Errorf("count of nothing %d", count)
+	}
+}
@@ -0,0 +1,176 @@
+package stream
+
+import (
+	"runtime"
+	"sync"
+)
+
+// iterator pushes one source element at a time through the stage chain and
+// hands out what reaches the end of it. Elements are always pulled
+// sequentially, also from a parallel stream. A generated source is pulled
+// lazily, see pull. The iterator is one evaluation of stream, its error is
+// kept once the elements are exhausted.
+type iterator struct {
+	stream      *pipeline
+	ev          *evaluation
+	sourceStage *pipeline
+	headStage   *pipeline
+	span        *span
+	buffer      *bufferSink
+	next        func() (interface{}, bool)
+	stop, wait  func()
+	finished    bool
+}
+
+func (p *pipeline) Iterator() Iterator {
+	return p.iterate(nil)
+}
+
+// iterate returns an iterator over p for down, the evaluation reading it,
+// whose settings it inherits.
+func (p *pipeline) iterate(down *evaluation) *iterator {
+	t := &pipeline{
+		name:          "Iterator",
+		previousStage: p,
+		sourceStage:   p.sourceStage,
+	}
+	buffer := &bufferSink{limit: -1}
+	ev := newEvaluation(t, down)
+	it := &iterator{
+		stream:      p,
+		ev:          ev,
+		sourceStage: p.sourceStage,
+		span:        &span{evaluation: ev},
+		buffer:      buffer,
+	}
+	it.headStage = chain(p.sourceStage, t, buffer, it.span)
+	if p.sourceStage.fill == nil && p.sourceStage.generate != nil {
+		it.next, it.stop, it.wait = pull(p.sourceStage, ev)
+		runtime.SetFinalizer(it, func(it *iterator) {
+			it.stop()
+		})
+	} else {
+		data, index := p.sourceStage.elements(ev), 0
+		it.next = func() (interface{}, bool) {
+			if index == len(data) {
+				return nil, false
+			}
+			index++
+			return data[index-1], true
+		}
+		it.stop, it.wait = func() {}, func() {}
+	}
+	return it
+}
+
+// pull runs the generator of sourceStage on a goroutine of its own, started by
+// the first call to next and kept one element ahead of it. stop makes the
+// generator return, the iterator calls it once exhausted, cancelled or closed
+// and when it is garbage collected. wait waits for the generator to return.
+// An error of the generator fails ev, a panic is raised again by next.
+func pull(sourceStage *pipeline, ev *evaluation) (next func() (interface{}, bool), stop, wait func()) {
+	elements := make(chan interface{})
+	done := make(chan struct{})
+	var start, closeDone sync.Once
+	var started bool
+	var panicked interface{}
+	next = func() (interface{}, bool) {
+		start.Do(func() {
+			started = true
+			go func() {
+				defer close(elements)
+				defer func() {
+					panicked = recover()
+				}()
+				ev.withLabels(func() {
+					sourceStage.source(ev)(func(v interface{}) bool {
+						select {
+						case elements <- v:
+							return true
+						case <-done:
+							return false
+						}
+					})
+				})
+			}()
+		})
+		v, ok := <-elements
+		if !ok && panicked != nil {
+			panic(panicked)
+		}
+		return v, ok
+	}
+	stop = func() {
+		closeDone.Do(func() {
+			close(done)
+		})
+	}
+	wait = func() {
+		if started {
+			for range elements {
+			}
+		}
+	}
+	return next, stop, wait
+}
+
+func (it *iterator) HasNext() bool {
+	for len(it.buffer.data) == 0 && !it.finished && !it.headStage.cancelled() {
+		v, ok := it.next()
+		if !ok {
+			break
+		}
+		it.ev.withLabels(func() {
+			it.headStage.do(it.headStage.nextStage, v)
+		})
+		it.span.index++
+	}
+	if len(it.buffer.data) == 0 && !it.finished {
+		it.finished = true
+		it.stop()
+		it.wait()
+		it.ev.withLabels(func() {
+			finish(it.headStage)
+		})
+		it.stream.setErr(it.ev.error())
+	}
+	return len(it.buffer.data) > 0
+}
+
+func (it *iterator) Close() {
+	if it.finished {
+		return
+	}
+	it.finished = true
+	it.buffer.data = nil
+	it.stop()
+	it.wait()
+	runtime.SetFinalizer(it, nil)
+}
+
+func (it *iterator) Next() interface{} {
+	if !it.HasNext() {
+		panic("no more elements")
+	}
+	v := it.buffer.data[0]
+	it.buffer.data[0] = nil
+	it.buffer.data = it.buffer.data[1:]
+	return v
+}
+
+// All always evaluates sequentially, the body of a range loop must not be
+// called concurrently.
+func (p *pipeline) All() func(yield func(v interface{}) bool) {
+	return func(yield func(v interface{}) bool) {
+		t := &pipeline{
+			name:          "All",
+			previousStage: p,
+			sourceStage:   p.sourceStage,
+			newSink: func() sink {
+				return &yieldSink{yield: yield}
+			},
+		}
+		ForkJoinOp{}.EvaluateSequential(t)
+		p.setErr(t.Err())
+	}
+}
@@ -0,0 +1,92 @@
+package stream
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestIterator(t *testing.T) {
+	students := createStudents()
+	peeked := 0
+	it := New(students).Peek(func(v interface{}) {
+		peeked++
+	}).Map(func(v interface{}) interface{} {
+		return v.(student).scores
+	}).FlatMap(func(v interface{}) interface{} {
+		return v
+	}).Filter(func(v interface{}) bool {
+		return v.(int) > 80
+	}).Iterator()
+	for it.HasNext() {
+		fmt.Println(it.Next())
+	}
+
+	peeked = 0
+	it = New(students).Peek(func(v interface{}) {
+		peeked++
+	}).Iterator()
+	it.Next()
+	it.Next()
+	if peeked != 2 {
+		t.Errorf("peeked %d elements, want 2", peeked)
+	}
+
+	it = New([]int{}).Iterator()
+	if it.HasNext() {
+		t.Error("empty stream has next")
+	}
+}
+
+func TestIteratorPullsLazily(t *testing.T) {
+	stopped := make(chan struct{})
+	naturals := Generate(func(yield func(v interface{}) bool) {
+		defer close(stopped)
+		for i := 0; yield(i); i++ {
+		}
+	})
+	it := naturals.Filter(func(v interface{}) bool {
+		return v.(int)%2 == 0
+	}).Iterator()
+	var res []interface{}
+	for len(res) < 3 && it.HasNext() {
+		res = append(res, it.Next())
+	}
+	if !reflect.DeepEqual(res, []interface{}{0, 2, 4}) {
+		t.Errorf("res %v", res)
+	}
+	it = nil
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		runtime.GC()
+		select {
+		case <-stopped:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the generator of an abandoned iterator is still running")
+		}
+	}
+}
+
+func TestIteratorClose(t *testing.T) {
+	stopped := false
+	it := Generate(func(yield func(v interface{}) bool) {
+		for i := 0; yield(i); i++ {
+		}
+		stopped = true
+	}).Iterator()
+	if it.Next() != 0 {
+		t.Error("first element")
+	}
+	it.Close()
+	if !stopped {
+		t.Error("the generator is still running after Close")
+	}
+	if it.HasNext() {
+		t.Error("closed iterator has next")
+	}
+	New([]int{1, 2}).Iterator().Close()
+}
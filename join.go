@@ -0,0 +1,190 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// JoinKind selects the unmatched elements a join passes on, paired with nil.
+type JoinKind int
+
+const (
+	// InnerJoin passes on the matching pairs only.
+	InnerJoin JoinKind = iota
+	// LeftOuterJoin also passes on the unmatched elements of the left stream.
+	LeftOuterJoin
+	// FullOuterJoin also passes on the unmatched elements of both streams.
+	FullOuterJoin
+)
+
+func (p *pipeline) Join(other Stream, leftKey, rightKey Function, combiner BiFunction) Stream {
+	t, _ := p.hashJoin("Join", InnerJoin, other, leftKey, rightKey, combiner)
+	return t
+}
+
+func (p *pipeline) LeftJoin(other Stream, leftKey, rightKey Function, combiner BiFunction) Stream {
+	t, _ := p.hashJoin("LeftJoin", LeftOuterJoin, other, leftKey, rightKey, combiner)
+	return t
+}
+
+func (p *pipeline) FullJoin(other Stream, leftKey, rightKey Function, combiner BiFunction) Stream {
+	t := p.barrierSource("FullJoin")
+	join, index := p.hashJoin("FullJoin", FullOuterJoin, other, leftKey, rightKey, combiner)
+	t.fill = func(down *evaluation) []interface{} {
+		s, err := join.collectFor(down, "FullJoin", func() sink {
+			return &bufferSink{limit: -1}
+		})
+		t.fillErr = err
+		data := s.(buffered).buffer()
+		index.build(down)
+		for i, v := range index.elements {
+			if atomic.LoadInt32(&index.matched[i]) == 0 {
+				data = append(data, combiner(nil, v))
+			}
+		}
+		return data
+	}
+	return t
+}
+
+// hashJoin indexes the elements of other by rightKey on the first element and
+// passes every element of p on with its matches. An error of other fails
+// every evaluation of the join.
+func (p *pipeline) hashJoin(name string, kind JoinKind, other Stream, leftKey, rightKey Function, combiner BiFunction) (*pipeline, *joinIndex) {
+	nilCheck(other)
+	nilCheck(leftKey)
+	nilCheck(rightKey)
+	nilCheck(combiner)
+	index := &joinIndex{other: other.(*pipeline), key: rightKey}
+	return &pipeline{
+		name:          name,
+		previousStage: p,
+		sourceStage:   p.sourceStage,
+		forkStage: func(r *span) (func(nextStage *pipeline, v interface{}), func(nextStage *pipeline)) {
+			return func(nextStage *pipeline, v interface{}) {
+				index.build(r.evaluation)
+				if index.err != nil {
+					r.fail(index.err)
+					return
+				}
+				matches := index.positions[leftKey(v)]
+				for _, i := range matches {
+					if kind == FullOuterJoin {
+						atomic.StoreInt32(&index.matched[i], 1)
+					}
+					nextStage.do(nextStage.nextStage, combiner(v, index.elements[i]))
+				}
+				if len(matches) == 0 && kind != InnerJoin {
+					nextStage.do(nextStage.nextStage, combiner(v, nil))
+				}
+			}, nil
+		},
+	}, index
+}
+
+// joinIndex is the hash index of the right stream of a join, built once and
+// shared by the workers. err is the error of the evaluation of other.
+type joinIndex struct {
+	once      sync.Once
+	other     *pipeline
+	key       Function
+	err       error
+	elements  []interface{}
+	positions map[interface{}][]int
+	matched   []int32
+}
+
+func (j *joinIndex) build(down *evaluation) {
+	j.once.Do(func() {
+		s, err := j.other.collectFor(down, "Join", func() sink {
+			return &bufferSink{limit: -1}
+		})
+		j.elements, j.err = s.(buffered).buffer(), err
+		j.positions = make(map[interface{}][]int)
+		for i, v := range j.elements {
+			key := j.key(v)
+			j.positions[key] = append(j.positions[key], i)
+		}
+		j.matched = make([]int32, len(j.elements))
+	})
+}
+
+func (p *pipeline) MergeJoin(other Stream, kind JoinKind, leftKey, rightKey Function, comparator Comparator, combiner BiFunction) Stream {
+	nilCheck(other)
+	nilCheck(leftKey)
+	nilCheck(rightKey)
+	nilCheck(comparator)
+	nilCheck(combiner)
+	t := p.barrierSource("MergeJoin")
+	t.parallel = false
+	t.generate = func(down *evaluation, yield func(v interface{}) bool) error {
+		left, right := p.iterate(down), other.(*pipeline).iterate(down)
+		defer left.Close()
+		defer right.Close()
+		m := &mergeJoin{left: left, right: right, rightKey: rightKey}
+		m.next()
+		m.join(kind, leftKey, comparator, combiner, yield)
+		if err := left.ev.error(); err != nil {
+			return err
+		}
+		return right.ev.error()
+	}
+	return t
+}
+
+// mergeJoin walks two streams sorted by key, rv is the next right element
+// and group the right elements matching the last left key.
+type mergeJoin struct {
+	left, right Iterator
+	rightKey    Function
+	rv, rk      interface{}
+	more        bool
+	group       []interface{}
+	groupKey    interface{}
+}
+
+func (m *mergeJoin) next() {
+	m.more = m.right.HasNext()
+	if m.more {
+		m.rv = m.right.Next()
+		m.rk = m.rightKey(m.rv)
+	}
+}
+
+func (m *mergeJoin) join(kind JoinKind, leftKey Function, comparator Comparator, combiner BiFunction, yield func(v interface{}) bool) {
+	equal := func(i, j interface{}) bool {
+		return !comparator(i, j) && !comparator(j, i)
+	}
+	for m.left.HasNext() {
+		lv := m.left.Next()
+		lk := leftKey(lv)
+		if m.group == nil || !equal(m.groupKey, lk) {
+			m.group = nil
+			for m.more && comparator(m.rk, lk) {
+				if kind == FullOuterJoin && !yield(combiner(nil, m.rv)) {
+					return
+				}
+				m.next()
+			}
+			for m.more && equal(m.rk, lk) {
+				m.group = append(m.group, m.rv)
+				m.groupKey = m.rk
+				m.next()
+			}
+		}
+		for _, rv := range m.group {
+			if !yield(combiner(lv, rv)) {
+				return
+			}
+		}
+		if m.group == nil && kind != InnerJoin && !yield(combiner(lv, nil)) {
+			return
+		}
+	}
+	for kind == FullOuterJoin && m.more {
+		if !yield(combiner(nil, m.rv)) {
+			return
+		}
+		m.next()
+	}
+}
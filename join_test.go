@@ -0,0 +1,128 @@
+package stream
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+)
+
+type enrollment struct {
+	student string
+	class   int
+}
+
+type class struct {
+	id   int
+	name string
+}
+
+func pair(l, r interface{}) interface{} {
+	var student, name string
+	if l != nil {
+		student = l.(enrollment).student
+	}
+	if r != nil {
+		name = r.(class).name
+	}
+	return fmt.Sprintf("%s:%s", student, name)
+}
+
+func TestJoin(t *testing.T) {
+	enrollments := []enrollment{{"Kate", 1}, {"Tom", 2}, {"Kate", 3}, {"Ann", 1}}
+	classes := []class{{1, "math"}, {3, "art"}, {4, "music"}, {1, "algebra"}}
+	leftKey := func(v interface{}) interface{} {
+		return v.(enrollment).class
+	}
+	rightKey := func(v interface{}) interface{} {
+		return v.(class).id
+	}
+	inner := []string{"Kate:math", "Kate:algebra", "Kate:art", "Ann:math", "Ann:algebra"}
+	left := []string{"Kate:math", "Kate:algebra", "Tom:", "Kate:art", "Ann:math", "Ann:algebra"}
+	full := append(append([]string{}, left...), ":music")
+	for _, parallel := range []bool{false, true} {
+		var joined, leftJoined, fullJoined []string
+		stream(enrollments, parallel).Join(New(classes), leftKey, rightKey, pair).ToSlice(&joined)
+		stream(enrollments, parallel).LeftJoin(New(classes), leftKey, rightKey, pair).ToSlice(&leftJoined)
+		stream(enrollments, parallel).FullJoin(New(classes), leftKey, rightKey, pair).ToSlice(&fullJoined)
+		if !reflect.DeepEqual(joined, inner) {
+			t.Errorf("parallel=%v: join %v", parallel, joined)
+		}
+		if !reflect.DeepEqual(leftJoined, left) {
+			t.Errorf("parallel=%v: left join %v", parallel, leftJoined)
+		}
+		if !reflect.DeepEqual(fullJoined, full) {
+			t.Errorf("parallel=%v: full join %v", parallel, fullJoined)
+		}
+	}
+
+	sortedBy := func(key Function) Comparator {
+		return func(i, j interface{}) bool {
+			return key(i).(int) < key(j).(int)
+		}
+	}
+	less := func(i, j interface{}) bool {
+		return i.(int) < j.(int)
+	}
+	for kind, want := range map[JoinKind][]string{
+		InnerJoin:     inner,
+		LeftOuterJoin: left,
+		FullOuterJoin: full,
+	} {
+		var merged []string
+		New(enrollments).Sorted(sortedBy(leftKey)).MergeJoin(
+			New(classes).Sorted(sortedBy(rightKey)), kind, leftKey, rightKey, less, pair,
+		).ToSlice(&merged)
+		sort.Strings(merged)
+		sorted := append([]string{}, want...)
+		sort.Strings(sorted)
+		if !reflect.DeepEqual(merged, sorted) {
+			t.Errorf("merge join %v: %v", kind, merged)
+		}
+	}
+}
+
+func TestParallelSelfJoin(t *testing.T) {
+	base := Parallel(createInts(2000)).Filter(func(v interface{}) bool {
+		return v.(int)%2 == 0
+	})
+	key := func(v interface{}) interface{} {
+		return v.(int) / 10
+	}
+	joined := base.Join(base, key, key, func(l, r interface{}) interface{} {
+		return l
+	})
+	if n := joined.Count(); n != 5000 {
+		t.Errorf("count %d, want 5000", n)
+	}
+	if n := base.Count(); n != 1000 {
+		t.Errorf("count %d, want 1000", n)
+	}
+}
+
+func TestMergeJoinStopsSources(t *testing.T) {
+	var running int32
+	naturals := func() Stream {
+		return Generate(func(yield func(v interface{}) bool) {
+			atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for i := 0; yield(i); i++ {
+			}
+		})
+	}
+	identity := func(v interface{}) interface{} {
+		return v
+	}
+	less := func(i, j interface{}) bool {
+		return i.(int) < j.(int)
+	}
+	first := naturals().MergeJoin(naturals(), InnerJoin, identity, identity, less, func(l, r interface{}) interface{} {
+		return l
+	}).FindFirst(func(v interface{}) bool {
+		return v.(int) > 5
+	})
+	if first != 6 || atomic.LoadInt32(&running) != 0 {
+		t.Errorf("first %v, %d sources still running", first, running)
+	}
+}
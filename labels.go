@@ -0,0 +1,65 @@
+package stream
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+func (p *pipeline) Label(ctx context.Context, pipeline string) Stream {
+	nilCheck(ctx)
+	t := p.settingStage("Label")
+	t.labelContext = ctx
+	t.pipelineLabel = pipeline
+	return t
+}
+
+func (p *pipeline) Named(stage string) Stream {
+	t := p.settingStage("Named")
+	t.label = stage
+	return t
+}
+
+// withLabels runs f with the pipeline label of e and restores the labels of
+// its context afterwards.
+func (e *evaluation) withLabels(f func()) {
+	if e.labels == nil {
+		f()
+		return
+	}
+	pprof.Do(e.labels.labelContext, pprof.Labels("pipeline", e.labels.pipelineLabel), func(context.Context) {
+		f()
+	})
+}
+
+// stageLabels returns the label contexts of stages, followed by the context
+// with only the pipeline label, or nil when e isn't labeled.
+func (e *evaluation) stageLabels(stages []*pipeline) []context.Context {
+	if e.labels == nil {
+		return nil
+	}
+	base := pprof.WithLabels(e.labels.labelContext, pprof.Labels("pipeline", e.labels.pipelineLabel))
+	labels := make([]context.Context, len(stages)+1)
+	for i, stage := range stages {
+		label := e.names[stage]
+		if label == "" {
+			label = stage.name
+		}
+		labels[i] = pprof.WithLabels(base, pprof.Labels("stage", label))
+	}
+	labels[len(stages)] = base
+	return labels
+}
+
+// labeled runs do, the i-th stage of a chain, under its label and restores the
+// label of the stage that called it.
+func labeled(labels []context.Context, i int, do func(nextStage *pipeline, v interface{})) func(nextStage *pipeline, v interface{}) {
+	restore := labels[len(labels)-1]
+	if i > 0 {
+		restore = labels[i-1]
+	}
+	return func(nextStage *pipeline, v interface{}) {
+		pprof.SetGoroutineLabels(labels[i])
+		do(nextStage, v)
+		pprof.SetGoroutineLabels(restore)
+	}
+}
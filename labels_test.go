@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"context"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLabel(t *testing.T) {
+	var lock sync.Mutex
+	profiles := make(map[string][]string)
+	profile := func(stage string) {
+		var b strings.Builder
+		pprof.Lookup("goroutine").WriteTo(&b, 1)
+		lock.Lock()
+		defer lock.Unlock()
+		profiles[stage] = append(profiles[stage], b.String())
+	}
+	Parallel(createStudents()).Label(context.Background(), "students").Filter(func(v interface{}) bool {
+		profile("adults")
+		return v.(student).age > 20
+	}).Named("adults").ForEach(func(v interface{}) {
+		profile("ForEach")
+	})
+	for stage, stageProfiles := range profiles {
+		for _, p := range stageProfiles {
+			if !strings.Contains(p, `"pipeline":"students"`) || !strings.Contains(p, `"stage":"`+stage+`"`) {
+				t.Errorf("labels of %s missing from goroutine profile:\n%s", stage, p)
+			}
+		}
+	}
+}
+
+func TestLabelBranches(t *testing.T) {
+	var profiles []string
+	profile := func(v interface{}) bool {
+		var b strings.Builder
+		pprof.Lookup("goroutine").WriteTo(&b, 1)
+		profiles = append(profiles, b.String())
+		return true
+	}
+	base := New(createInts(3))
+	base.Label(context.Background(), "left")
+	base.Filter(profile).Label(context.Background(), "right").Count()
+	base.Filter(profile).Sorted(func(i, j interface{}) bool {
+		return i.(int) < j.(int)
+	}).Label(context.Background(), "sorted").Count()
+	for i, p := range profiles {
+		want := "right"
+		if i >= 3 {
+			want = "sorted"
+		}
+		if !strings.Contains(p, `"pipeline":"`+want+`"`) || strings.Contains(p, `"pipeline":"left"`) {
+			t.Errorf("element %d not labeled %s:\n%s", i, want, p)
+		}
+	}
+}
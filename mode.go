@@ -0,0 +1,60 @@
+package stream
+
+func (p *pipeline) Sequential() Stream {
+	return p.switchMode("Sequential", false)
+}
+
+func (p *pipeline) Parallel() Stream {
+	return p.switchMode("Parallel", true)
+}
+
+// switchMode returns p if it already runs in the parallel mode, otherwise a
+// new source stage running in it over the elements of p.
+func (p *pipeline) switchMode(name string, parallel bool) Stream {
+	if p.sourceStage.parallel == parallel {
+		return p
+	}
+	var t *pipeline
+	if p == p.sourceStage {
+		t = p.barrierSource(name)
+		t.fill = func(down *evaluation) []interface{} {
+			ev := newEvaluation(p, down)
+			data := p.elements(ev)
+			t.fillErr = ev.error()
+			p.setErr(t.fillErr)
+			return data
+		}
+	} else {
+		t = p.barrier(name, func() sink {
+			return &bufferSink{limit: -1}
+		})
+	}
+	t.parallel = parallel
+	return t
+}
+
+func (p *pipeline) Unordered() Stream {
+	return &pipeline{
+		name:          "Unordered",
+		previousStage: p,
+		sourceStage:   p.sourceStage,
+		unordered:     true,
+		do: func(nextStage *pipeline, v interface{}) {
+			nextStage.do(nextStage.nextStage, v)
+		},
+	}
+}
+
+// ordered reports whether the stages downstream of p must keep the encounter
+// order, that is whether Unordered was not called since the first source.
+func (p *pipeline) ordered() bool {
+	for stage := p; stage != nil; stage = stage.previousStage {
+		if stage.unordered {
+			return false
+		}
+		if stage == stage.sourceStage {
+			break
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSwitchMode(t *testing.T) {
+	ints := createInts(1000)
+	even := func(v interface{}) bool {
+		return v.(int)%2 == 0
+	}
+	double := func(v interface{}) interface{} {
+		return v.(int) * 2
+	}
+	plan := New(ints).Filter(even).Parallel().Map(double).Sequential().Explain()
+	want := "0 Source\n1 Filter\n2 Parallel barrier parallel\n3 Map parallel\n4 Sequential barrier\n"
+	if plan.String() != want {
+		t.Errorf("plan\n%s\nwant\n%s", plan, want)
+	}
+	if s := New(ints).Sequential(); len(s.Explain().Stages) != 1 {
+		t.Errorf("Sequential on a sequential stream added a stage")
+	}
+	var sequential, got []int
+	New(ints).Filter(even).Map(double).ToSlice(&sequential)
+	Parallel(ints).Sequential().Filter(even).Parallel().Map(double).ToSlice(&got)
+	if !reflect.DeepEqual(got, sequential) {
+		t.Errorf("switching modes changed the result")
+	}
+}
+
+func TestUnordered(t *testing.T) {
+	ints := createInts(10000)
+	kept := make(map[int]bool)
+	for _, v := range ints {
+		kept[v] = true
+	}
+	var limited []int
+	Parallel(ints).Unordered().Limit(10).ToSlice(&limited)
+	if len(limited) != 10 {
+		t.Errorf("limited %v", limited)
+	}
+	for _, v := range limited {
+		if !kept[v] {
+			t.Errorf("limited %v", limited)
+		}
+	}
+	first := Parallel(ints).Unordered().Map(func(v interface{}) interface{} {
+		return v
+	}).FindFirst(func(v interface{}) bool {
+		return v.(int)%100 == 7
+	})
+	if first == nil || first.(int)%100 != 7 {
+		t.Errorf("first %v", first)
+	}
+	var sorted []int
+	Parallel(ints).Unordered().Sorted(func(i, j interface{}) bool {
+		return i.(int) < j.(int)
+	}).Limit(3).ToSlice(&sorted)
+	if !reflect.DeepEqual(sorted, []int{0, 1, 2}) {
+		t.Errorf("sorted %v", sorted)
+	}
+}
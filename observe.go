@@ -0,0 +1,182 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Observer is told how the stages of a stream did once an evaluation, of the
+// terminal operation or of a barrier, has finished.
+type Observer interface {
+	// ObserveStage is called for every stage of the evaluated segment, the
+	// terminal or barrier stage last.
+	ObserveStage(stats StageStats)
+	// ObserveEvaluation is called after the stages with the total duration
+	// of the evaluation.
+	ObserveEvaluation(kind string, elapsed time.Duration)
+}
+
+// StageStats holds the counters of one stage. In is the number of elements the
+// stage received and Out the number it passed on, Out is zero for the
+// Terminal stage of an evaluation. Elapsed is the time spent in the stage's
+// callbacks, for the elements and at the end of each range, excluding the
+// stages downstream of it, summed over all workers.
+type StageStats struct {
+	Position int
+	Kind     string
+	Terminal bool
+	In, Out  int64
+	Elapsed  time.Duration
+}
+
+type stageCounter struct {
+	in      int64
+	elapsed time.Duration
+}
+
+// observedSink counts the elements and times the callbacks of one range. The
+// stages of a range run on one goroutine, running is the stage whose callback
+// runs since started, -1 for none, and the stage calling it is paused.
+type observedSink struct {
+	sink
+	counters []stageCounter
+	running  int
+	started  time.Time
+}
+
+// enter starts timing the i-th stage and returns the stage to resume.
+func (o *observedSink) enter(i int) int {
+	now := time.Now()
+	caller := o.running
+	if caller >= 0 {
+		o.counters[caller].elapsed += now.Sub(o.started)
+	}
+	o.running, o.started = i, now
+	return caller
+}
+
+func (o *observedSink) leave(caller int) {
+	now := time.Now()
+	o.counters[o.running].elapsed += now.Sub(o.started)
+	o.running, o.started = caller, now
+}
+
+// wrap counts and times do, the i-th stage of the chain.
+func (o *observedSink) wrap(i int, do func(nextStage *pipeline, v interface{})) func(nextStage *pipeline, v interface{}) {
+	counter := &o.counters[i]
+	return func(nextStage *pipeline, v interface{}) {
+		counter.in++
+		caller := o.enter(i)
+		do(nextStage, v)
+		o.leave(caller)
+	}
+}
+
+// wrapEnd times end, the i-th stage of the chain, which may pass on the
+// elements it held back.
+func (o *observedSink) wrapEnd(i int, end func(nextStage *pipeline)) func(nextStage *pipeline) {
+	if end == nil {
+		return nil
+	}
+	return func(nextStage *pipeline) {
+		caller := o.enter(i)
+		end(nextStage)
+		o.leave(caller)
+	}
+}
+
+func (o *observedSink) accept(v interface{}) {
+	o.counters[len(o.counters)-1].in++
+	caller := o.enter(len(o.counters) - 1)
+	o.sink.accept(v)
+	o.leave(caller)
+}
+
+func (o *observedSink) end() {
+	caller := o.enter(len(o.counters) - 1)
+	o.sink.end()
+	o.leave(caller)
+}
+
+func (o *observedSink) combine(right sink) {
+	r := right.(*observedSink)
+	for i := range o.counters {
+		o.counters[i].in += r.counters[i].in
+		o.counters[i].elapsed += r.counters[i].elapsed
+	}
+	o.sink.combine(r.sink)
+}
+
+// observe reports the counters of an observed evaluation ev to its observers
+// and returns the sink of the terminal stage.
+func observe(ev *evaluation, terminal *pipeline, s sink, start time.Time) sink {
+	observed, ok := s.(*observedSink)
+	if !ok {
+		return s
+	}
+	elapsed := time.Since(start)
+	sourceStage := terminal.sourceStage
+	stages := append(segment(sourceStage, terminal), terminal)
+	offset := len(sourceStage.Explain().Stages)
+	for _, stage := range ev.observers {
+		observer := stage.observer
+		for i, stage := range stages {
+			stats := StageStats{
+				Position: offset + i,
+				Kind:     stage.name,
+				In:       observed.counters[i].in,
+				Elapsed:  observed.counters[i].elapsed,
+			}
+			if i+1 < len(stages) {
+				stats.Out = observed.counters[i+1].in
+			} else {
+				stats.Terminal = true
+			}
+			observer.ObserveStage(stats)
+		}
+		observer.ObserveEvaluation(terminal.name, elapsed)
+	}
+	return observed.sink
+}
+
+func (p *pipeline) Observe(observer Observer) Stream {
+	nilCheck(observer)
+	t := p.settingStage("Observe")
+	t.observer = observer
+	return t
+}
+
+// StatsCollector is an Observer that keeps everything it is told for a report.
+type StatsCollector struct {
+	Stages      []StageStats
+	Evaluations []string
+	Elapsed     time.Duration
+}
+
+func (c *StatsCollector) ObserveStage(stats StageStats) {
+	c.Stages = append(c.Stages, stats)
+}
+
+func (c *StatsCollector) ObserveEvaluation(kind string, elapsed time.Duration) {
+	c.Evaluations = append(c.Evaluations, kind)
+	c.Elapsed += elapsed
+}
+
+// Report renders the collected stage stats as a table.
+func (c *StatsCollector) Report() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POS\tSTAGE\tIN\tOUT\tDROPPED\tELAPSED")
+	for _, s := range c.Stages {
+		out, dropped := "-", "-"
+		if !s.Terminal {
+			out, dropped = fmt.Sprint(s.Out), fmt.Sprint(s.In-s.Out)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\n", s.Position, s.Kind, s.In, out, dropped, s.Elapsed)
+	}
+	w.Flush()
+	fmt.Fprintf(&b, "%s took %s\n", strings.Join(c.Evaluations, ", "), c.Elapsed)
+	return b.String()
+}
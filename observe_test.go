@@ -0,0 +1,85 @@
+package stream
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	collector := &StatsCollector{}
+	count := Parallel(createInts(1000)).Observe(collector).Filter(func(v interface{}) bool {
+		return v.(int)%2 == 0
+	}).Sorted(func(i, j interface{}) bool {
+		return i.(int) < j.(int)
+	}).Map(func(v interface{}) interface{} {
+		return v.(int) / 2
+	}).Count()
+	fmt.Print(collector.Report())
+
+	if count != 500 {
+		t.Errorf("count %d, want 500", count)
+	}
+	want := []StageStats{
+		{Position: 1, Kind: "Filter", In: 1000, Out: 500},
+		{Position: 2, Kind: "Sorted", Terminal: true, In: 500},
+		{Position: 3, Kind: "Map", In: 500, Out: 500},
+		{Position: 4, Kind: "Count", Terminal: true, In: 500},
+	}
+	if len(collector.Stages) != len(want) {
+		t.Fatalf("stages %v", collector.Stages)
+	}
+	for i, s := range collector.Stages {
+		s.Elapsed = 0
+		if s != want[i] {
+			t.Errorf("stage %v, want %v", s, want[i])
+		}
+	}
+}
+
+func TestObserveBranches(t *testing.T) {
+	less := func(i, j interface{}) bool {
+		return i.(int) < j.(int)
+	}
+	base := New(createInts(10))
+	sibling := &StatsCollector{}
+	base.Observe(sibling)
+	left, right := &StatsCollector{}, &StatsCollector{}
+	base.Filter(func(v interface{}) bool {
+		return v.(int) > 2
+	}).Sorted(less).Observe(left).Count()
+	base.Sorted(less).Observe(right).Count()
+	if len(sibling.Evaluations) != 0 {
+		t.Errorf("sibling observed %v", sibling.Evaluations)
+	}
+	if !reflect.DeepEqual(left.Evaluations, []string{"Sorted", "Count"}) || len(left.Stages) != 3 {
+		t.Errorf("left observed %v: %v", left.Evaluations, left.Stages)
+	}
+	if !reflect.DeepEqual(right.Evaluations, []string{"Sorted", "Count"}) || len(right.Stages) != 2 {
+		t.Errorf("right observed %v: %v", right.Evaluations, right.Stages)
+	}
+}
+
+func TestObserveEnd(t *testing.T) {
+	collector := &StatsCollector{}
+	count := New(createInts(3)).Observe(collector).Then(func() Stage {
+		return &pairs{}
+	}).Map(func(v interface{}) interface{} {
+		time.Sleep(10 * time.Millisecond)
+		return v
+	}).Count()
+	fmt.Print(collector.Report())
+
+	if count != 2 || len(collector.Stages) != 3 {
+		t.Fatalf("count %d, stages %v", count, collector.Stages)
+	}
+	for _, s := range collector.Stages {
+		if s.Elapsed < 0 {
+			t.Errorf("stage %v", s)
+		}
+	}
+	if s := collector.Stages[1]; s.In != 2 || s.Elapsed < 20*time.Millisecond {
+		t.Errorf("Map after a stage flushing on End: %v", s)
+	}
+}
@@ -0,0 +1,225 @@
+package stream
+
+import (
+	"math"
+	"sort"
+)
+
+type FloatFunction func(v interface{}) float64
+
+func (p *pipeline) Quantiles(key FloatFunction, qs ...float64) []float64 {
+	nilCheck(key)
+	values := p.collect("Quantiles", func() sink {
+		return &floatSink{key: key}
+	}).(*floatSink).values
+	sort.Float64s(values)
+	res := make([]float64, len(qs))
+	for i, q := range qs {
+		res[i] = quantile(values, q)
+	}
+	return res
+}
+
+// quantile interpolates linearly between the closest ranks of sorted values.
+func quantile(values []float64, q float64) float64 {
+	if len(values) == 0 || q < 0 || q > 1 {
+		return math.NaN()
+	}
+	pos := q * float64(len(values)-1)
+	i := int(pos)
+	if i+1 >= len(values) {
+		return values[len(values)-1]
+	}
+	return values[i] + (pos-float64(i))*(values[i+1]-values[i])
+}
+
+func (p *pipeline) Histogram(key FloatFunction, bounds []float64) []int {
+	nilCheck(key)
+	if !sort.Float64sAreSorted(bounds) {
+		panic("bounds must be sorted")
+	}
+	return p.collect("Histogram", func() sink {
+		return &histogramSink{key: key, bounds: bounds, counts: make([]int, len(bounds)+1)}
+	}).(*histogramSink).counts
+}
+
+func (p *pipeline) Digest(key FloatFunction, compression float64) *TDigest {
+	nilCheck(key)
+	return p.collect("Digest", func() sink {
+		return &digestSink{key: key, digest: NewTDigest(compression)}
+	}).(*digestSink).digest
+}
+
+type floatSink struct {
+	key    FloatFunction
+	values []float64
+}
+
+func (f *floatSink) accept(v interface{}) {
+	f.values = append(f.values, f.key(v))
+}
+func (f *floatSink) end() {
+}
+func (f *floatSink) cancellationRequested() bool {
+	return false
+}
+func (f *floatSink) combine(right sink) {
+	f.values = append(f.values, right.(*floatSink).values...)
+}
+
+type histogramSink struct {
+	key    FloatFunction
+	bounds []float64
+	counts []int
+}
+
+func (h *histogramSink) accept(v interface{}) {
+	x := h.key(v)
+	h.counts[sort.Search(len(h.bounds), func(i int) bool {
+		return x < h.bounds[i]
+	})]++
+}
+func (h *histogramSink) end() {
+}
+func (h *histogramSink) cancellationRequested() bool {
+	return false
+}
+func (h *histogramSink) combine(right sink) {
+	for i, count := range right.(*histogramSink).counts {
+		h.counts[i] += count
+	}
+}
+
+type digestSink struct {
+	key    FloatFunction
+	digest *TDigest
+}
+
+func (d *digestSink) accept(v interface{}) {
+	d.digest.Add(d.key(v))
+}
+func (d *digestSink) end() {
+}
+func (d *digestSink) cancellationRequested() bool {
+	return false
+}
+func (d *digestSink) combine(right sink) {
+	d.digest.Merge(right.(*digestSink).digest)
+}
+
+type centroid struct {
+	mean, weight float64
+}
+
+// TDigest is a merging t-digest, it keeps about compression centroids whatever
+// the number of values added and estimates quantiles most accurately near
+// the tails.
+type TDigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	count       float64
+	min, max    float64
+}
+
+// NewTDigest returns an empty digest, compression defaults to 100.
+func NewTDigest(compression float64) *TDigest {
+	if compression <= 0 {
+		compression = 100
+	}
+	return &TDigest{compression: compression, min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (t *TDigest) Add(x float64) {
+	t.buffer = append(t.buffer, centroid{mean: x, weight: 1})
+	t.count++
+	t.min = math.Min(t.min, x)
+	t.max = math.Max(t.max, x)
+	if float64(len(t.buffer)) > 5*t.compression {
+		t.compress()
+	}
+}
+
+// Merge adds the values summarized by other.
+func (t *TDigest) Merge(other *TDigest) {
+	t.buffer = append(t.buffer, other.centroids...)
+	t.buffer = append(t.buffer, other.buffer...)
+	t.count += other.count
+	t.min = math.Min(t.min, other.min)
+	t.max = math.Max(t.max, other.max)
+	t.compress()
+}
+
+func (t *TDigest) Count() int {
+	return int(t.count)
+}
+
+// compress merges neighbouring centroids as long as the merged centroid stays
+// within one unit of the k1 scale function.
+func (t *TDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	all := append(t.centroids, t.buffer...)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].mean < all[j].mean
+	})
+	merged := make([]centroid, 0, int(t.compression))
+	current := all[0]
+	soFar := 0.0
+	limit := t.limit(0)
+	for _, c := range all[1:] {
+		if (soFar+current.weight+c.weight)/t.count <= limit {
+			current.mean += (c.mean - current.mean) * c.weight / (current.weight + c.weight)
+			current.weight += c.weight
+		} else {
+			merged = append(merged, current)
+			soFar += current.weight
+			limit = t.limit(soFar / t.count)
+			current = c
+		}
+	}
+	t.centroids = append(merged, current)
+	t.buffer = nil
+}
+
+// limit returns the quantile one unit of the scale function right of q.
+func (t *TDigest) limit(q float64) float64 {
+	// the k1 scale function makes about delta/2 centroids
+	delta := 2 * t.compression
+	k := delta / (2 * math.Pi) * math.Asin(2*q-1)
+	return (math.Sin((k+1)*2*math.Pi/delta) + 1) / 2
+}
+
+// Quantile estimates the q quantile by interpolating between the centers of
+// the centroids.
+func (t *TDigest) Quantile(q float64) float64 {
+	t.compress()
+	if t.count == 0 || q < 0 || q > 1 {
+		return math.NaN()
+	}
+	target := q * t.count
+	first, last := t.centroids[0], t.centroids[len(t.centroids)-1]
+	if target <= first.weight/2 {
+		if first.weight == 1 {
+			return first.mean
+		}
+		return t.min + (first.mean-t.min)*target/(first.weight/2)
+	}
+	if target >= t.count-last.weight/2 {
+		if last.weight == 1 {
+			return last.mean
+		}
+		return last.mean + (t.max-last.mean)*(target-t.count+last.weight/2)/(last.weight/2)
+	}
+	soFar := first.weight / 2
+	for i := 1; i < len(t.centroids); i++ {
+		prev, c := t.centroids[i-1], t.centroids[i]
+		step := (prev.weight + c.weight) / 2
+		if target < soFar+step {
+			return prev.mean + (c.mean-prev.mean)*(target-soFar)/step
+		}
+		soFar += step
+	}
+	return last.mean
+}
@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func toFloat(v interface{}) float64 {
+	return float64(v.(int))
+}
+
+func TestQuantiles(t *testing.T) {
+	ints := createInts(101)
+	qs := Parallel(ints).Quantiles(toFloat, 0, 0.5, 0.95, 0.99, 1)
+	if !reflect.DeepEqual(qs, []float64{0, 50, 95, 99, 100}) {
+		t.Errorf("quantiles %v", qs)
+	}
+	if q := New([]int{1, 2}).Quantiles(toFloat, 0.5)[0]; q != 1.5 {
+		t.Errorf("median %v, want 1.5", q)
+	}
+	if q := New([]int{}).Quantiles(toFloat, 0.5)[0]; !math.IsNaN(q) {
+		t.Errorf("median of nothing %v", q)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	counts := Parallel(createInts(100)).Histogram(toFloat, []float64{10, 50, 90})
+	if !reflect.DeepEqual(counts, []int{10, 40, 40, 10}) {
+		t.Errorf("counts %v", counts)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	latencies := make([]float64, 100000)
+	for i := range latencies {
+		latencies[i] = rnd.ExpFloat64() * 100
+	}
+	key := func(v interface{}) float64 {
+		return v.(float64)
+	}
+	exact := New(latencies).Quantiles(key, 0.5, 0.95, 0.99, 0.999)
+	for _, s := range []Stream{New(latencies), Parallel(latencies)} {
+		digest := s.Digest(key, 100)
+		if digest.Count() != len(latencies) || len(digest.centroids) > 200 {
+			t.Errorf("%d values in %d centroids", digest.Count(), len(digest.centroids))
+		}
+		for i, q := range []float64{0.5, 0.95, 0.99, 0.999} {
+			if estimate := digest.Quantile(q); math.Abs(estimate-exact[i])/exact[i] > 0.02 {
+				t.Errorf("p%v estimated %v, exact %v", q*100, estimate, exact[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The streams read from an io.Reader read it lazily and only once, a read or
+// decoding error stops them and is returned by Err.
+
+// FromLines returns a stream of the lines of r without their line endings.
+func FromLines(r io.Reader) Stream {
+	nilCheck(r)
+	return generateErr("FromLines", func(yield func(v interface{}) bool) error {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if len(line) > 0 {
+				line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+				if !yield(line) {
+					return nil
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// CSVOptions configures FromCSV. Without a Prototype every record is a
+// []string.
+type CSVOptions struct {
+	Comma, Comment rune
+	LazyQuotes     bool
+	// Header skips the first record, which names the columns.
+	Header bool
+	// Prototype is a struct value, with Header set every record becomes a
+	// value of its type whose fields are set from the columns named like
+	// their csv tag or, without one, like the field.
+	Prototype interface{}
+}
+
+// FromCSV returns a stream of the records of r.
+func FromCSV(r io.Reader, opts CSVOptions) Stream {
+	nilCheck(r)
+	if opts.Prototype != nil && !opts.Header {
+		panic("mapping records to a prototype requires a header")
+	}
+	return generateErr("FromCSV", func(yield func(v interface{}) bool) error {
+		reader := csv.NewReader(r)
+		if opts.Comma != 0 {
+			reader.Comma = opts.Comma
+		}
+		reader.Comment = opts.Comment
+		reader.LazyQuotes = opts.LazyQuotes
+		var mapper *csvMapper
+		if opts.Header {
+			header, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if opts.Prototype != nil {
+				mapper = newCSVMapper(reflect.TypeOf(opts.Prototype), header)
+			}
+		}
+		for n := 1; ; n++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			var v interface{} = record
+			if mapper != nil {
+				if v, err = mapper.value(record); err != nil {
+					return fmt.Errorf("csv record %d: %w", n, err)
+				}
+			}
+			if !yield(v) {
+				return nil
+			}
+		}
+	})
+}
+
+// csvMapper sets the fields of a struct from the columns of a record.
+type csvMapper struct {
+	typ    reflect.Type
+	fields []int
+}
+
+func newCSVMapper(typ reflect.Type, header []string) *csvMapper {
+	if typ.Kind() != reflect.Struct {
+		panic("prototype must be a struct")
+	}
+	m := &csvMapper{typ: typ, fields: make([]int, len(header))}
+	for i, column := range header {
+		m.fields[i] = -1
+		for j := 0; j < typ.NumField(); j++ {
+			field := typ.Field(j)
+			name := field.Tag.Get("csv")
+			if name == "" {
+				name = field.Name
+			}
+			if field.PkgPath == "" && strings.EqualFold(name, strings.TrimSpace(column)) {
+				m.fields[i] = j
+				break
+			}
+		}
+	}
+	return m
+}
+
+func (m *csvMapper) value(record []string) (interface{}, error) {
+	v := reflect.New(m.typ).Elem()
+	for i, column := range record {
+		if i >= len(m.fields) || m.fields[i] < 0 {
+			continue
+		}
+		if err := setField(v.Field(m.fields[i]), column); err != nil {
+			return nil, fmt.Errorf("field %s: %w", m.typ.Field(m.fields[i]).Name, err)
+		}
+	}
+	return v.Interface(), nil
+}
+
+func setField(field reflect.Value, s string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported kind %s", field.Kind())
+	}
+	return nil
+}
+
+// FromJSONLines returns a stream of the JSON values of r, each decoded into a
+// new value of the prototype's type. A nil prototype decodes into
+// interface{}.
+func FromJSONLines(r io.Reader, prototype interface{}) Stream {
+	nilCheck(r)
+	return generateErr("FromJSONLines", func(yield func(v interface{}) bool) error {
+		decoder := json.NewDecoder(r)
+		for {
+			var v reflect.Value
+			if prototype == nil {
+				v = reflect.New(reflect.TypeOf((*interface{})(nil)).Elem())
+			} else {
+				v = reflect.New(reflect.TypeOf(prototype))
+			}
+			if err := decoder.Decode(v.Interface()); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if !yield(v.Elem().Interface()) {
+				return nil
+			}
+		}
+	})
+}
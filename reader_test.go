@@ -0,0 +1,96 @@
+package stream
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err == io.EOF {
+		return n, e.err
+	}
+	return n, err
+}
+
+func TestFromLines(t *testing.T) {
+	var lines []string
+	FromLines(strings.NewReader("Tom\r\nKate\n\nLucy")).ToSlice(&lines)
+	if !reflect.DeepEqual(lines, []string{"Tom", "Kate", "", "Lucy"}) {
+		t.Errorf("lines %q", lines)
+	}
+
+	broken := errors.New("broken")
+	s := FromLines(&errReader{r: strings.NewReader("Tom\nKate\n"), err: broken})
+	if count := s.Count(); count != 2 {
+		t.Errorf("count %d, want 2", count)
+	}
+	if s.Err() != broken {
+		t.Errorf("err %v, want %v", s.Err(), broken)
+	}
+}
+
+type csvStudent struct {
+	ID   int `csv:"id"`
+	Name string
+	Age  int
+}
+
+func TestFromCSV(t *testing.T) {
+	input := "id,name,age\n1,Tom,16\n2,Kate,22\n"
+	var records [][]string
+	FromCSV(strings.NewReader(input), CSVOptions{}).ToSlice(&records)
+	if len(records) != 3 || records[2][1] != "Kate" {
+		t.Errorf("records %v", records)
+	}
+
+	var students []csvStudent
+	s := FromCSV(strings.NewReader(input), CSVOptions{Header: true, Prototype: csvStudent{}})
+	s.ToSlice(&students)
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+	if !reflect.DeepEqual(students, []csvStudent{{1, "Tom", 16}, {2, "Kate", 22}}) {
+		t.Errorf("students %v", students)
+	}
+
+	s = FromCSV(strings.NewReader("id,age\n1,16\n2,old\n"), CSVOptions{Header: true, Prototype: csvStudent{}})
+	if count := s.Count(); count != 1 || s.Err() == nil {
+		t.Errorf("count %d, err %v", count, s.Err())
+	}
+}
+
+func TestFromJSONLines(t *testing.T) {
+	type score struct {
+		Name  string
+		Score int
+	}
+	input := `{"Name":"Tom","Score":80}
+{"Name":"Kate","Score":95}
+`
+	var scores []score
+	FromJSONLines(strings.NewReader(input), score{}).ToSlice(&scores)
+	if !reflect.DeepEqual(scores, []score{{"Tom", 80}, {"Kate", 95}}) {
+		t.Errorf("scores %v", scores)
+	}
+
+	first := FromJSONLines(strings.NewReader(input), nil).FindFirst(func(v interface{}) bool {
+		return true
+	})
+	if first.(map[string]interface{})["Name"] != "Tom" {
+		t.Errorf("first %v", first)
+	}
+
+	s := FromJSONLines(strings.NewReader(input+"{broken\n"), score{})
+	if count := s.Count(); count != 2 || s.Err() == nil {
+		t.Errorf("count %d, err %v", count, s.Err())
+	}
+}
@@ -0,0 +1,118 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is the error of an attempt that took longer than the Timeout of
+// its RetryPolicy.
+var ErrTimeout = errors.New("stream: element timed out")
+
+// RetryPolicy tells MapWithRetry how often and when to call a failing function
+// again. The backoff before the n-th retry is InitialBackoff*Multiplier^(n-1),
+// capped at MaxBackoff and reduced by a random fraction of up to Jitter.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Multiplier defaults to 2.
+	Multiplier float64
+	// Jitter is between 0 and 1.
+	Jitter float64
+	// Retryable reports whether an error is worth another attempt, every
+	// error is when it is nil.
+	Retryable func(err error) bool
+	// Timeout abandons an attempt that takes longer, zero means no timeout.
+	Timeout time.Duration
+	// Rand draws the jitter, defaults to the math/rand source.
+	Rand *rand.Rand
+}
+
+func (r *RetryPolicy) backoff(retry int, random func() float64) time.Duration {
+	multiplier := r.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	backoff := float64(r.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if r.MaxBackoff > 0 && backoff > float64(r.MaxBackoff) {
+		backoff = float64(r.MaxBackoff)
+	}
+	if r.Jitter > 0 {
+		backoff -= backoff * r.Jitter * random()
+	}
+	return time.Duration(backoff)
+}
+
+func (p *pipeline) MapWithRetry(function ErrFunction, policy RetryPolicy) Stream {
+	nilCheck(function)
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	clock := p.stageClock()
+	var randLock sync.Mutex
+	random := func() float64 {
+		randLock.Lock()
+		defer randLock.Unlock()
+		if policy.Rand != nil {
+			return policy.Rand.Float64()
+		}
+		return rand.Float64()
+	}
+	return &pipeline{
+		name:          "MapWithRetry",
+		previousStage: p,
+		sourceStage:   p.sourceStage,
+		forkStage: func(r *span) (func(nextStage *pipeline, v interface{}), func(nextStage *pipeline)) {
+			return func(nextStage *pipeline, v interface{}) {
+				var err error
+				for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+					if attempt > 1 {
+						clock.Sleep(policy.backoff(attempt-1, random))
+					}
+					var out interface{}
+					out, err = attemptWithTimeout(function, v, policy.Timeout, clock)
+					if err == nil {
+						nextStage.do(nextStage.nextStage, out)
+						return
+					}
+					if policy.Retryable != nil && !policy.Retryable(err) {
+						break
+					}
+				}
+				r.fail(fmt.Errorf("stream: %v failed: %w", v, err))
+			}, nil
+		},
+	}
+}
+
+// attemptWithTimeout calls function once, leaving it running in the background
+// if it takes longer than timeout. A panic of function within the timeout is
+// raised again on the calling goroutine.
+func attemptWithTimeout(function ErrFunction, v interface{}, timeout time.Duration, clock Clock) (interface{}, error) {
+	if timeout <= 0 {
+		return function(v)
+	}
+	type result struct {
+		out interface{}
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		done <- call(func(v interface{}) interface{} {
+			out, err := function(v)
+			return result{out: out, err: err}
+		}, v)
+	}()
+	select {
+	case o := <-done:
+		r := o.get().(result)
+		return r.out, r.err
+	case <-clock.After(timeout):
+		return nil, ErrTimeout
+	}
+}
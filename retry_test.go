@@ -0,0 +1,116 @@
+package stream
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient")
+
+func TestMapWithRetry(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	attempts := make(map[int]int)
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		Jitter:         0.5,
+		Rand:           rand.New(rand.NewSource(1)),
+		Retryable: func(err error) bool {
+			return err == errTransient
+		},
+	}
+	s := New([]int{1, 2, 3}).WithClock(clock).MapWithRetry(func(v interface{}) (interface{}, error) {
+		attempts[v.(int)]++
+		if attempts[v.(int)] < v.(int) {
+			return nil, errTransient
+		}
+		return v.(int) * 10, nil
+	}, policy)
+	var res []int
+	s.ToSlice(&res)
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, []int{10, 20, 30}) {
+		t.Errorf("res %v", res)
+	}
+	slept := clock.Now().Sub(start)
+	if slept < 175*time.Millisecond || slept > 350*time.Millisecond {
+		t.Errorf("slept %v, want 3 jittered backoffs of 100ms, 100ms and 200ms", slept)
+	}
+
+	s = New([]int{1, 2, 3, 4}).MapWithRetry(func(v interface{}) (interface{}, error) {
+		if v.(int) == 2 {
+			return nil, errors.New("permanent")
+		}
+		return v, nil
+	}, policy)
+	if count := s.Count(); count != 1 {
+		t.Errorf("count %d, want 1", count)
+	}
+	if s.Err() == nil {
+		t.Error("no error")
+	}
+}
+
+func TestMapWithRetryEvaluations(t *testing.T) {
+	base := New([]int{1, 2, 3})
+	failing := base.MapWithRetry(func(v interface{}) (interface{}, error) {
+		return nil, errors.New("permanent")
+	}, RetryPolicy{})
+	if count := failing.Count(); count != 0 || failing.Err() == nil {
+		t.Errorf("count %d, err %v", count, failing.Err())
+	}
+	if count := base.Count(); count != 3 || base.Err() != nil {
+		t.Errorf("count %d, err %v", count, base.Err())
+	}
+	sorted := failing.Sorted(func(i, j interface{}) bool {
+		return i.(int) < j.(int)
+	})
+	for i := 0; i < 2; i++ {
+		if count := sorted.Count(); count != 0 || sorted.Err() == nil {
+			t.Errorf("count %d, err %v", count, sorted.Err())
+		}
+	}
+}
+
+func TestMapWithRetryTimeout(t *testing.T) {
+	clock := newFakeClock()
+	release := make(chan struct{})
+	defer close(release)
+	s := New([]int{1, 2, 3}).WithClock(clock).MapWithRetry(func(v interface{}) (interface{}, error) {
+		for v.(int) == 2 {
+			select {
+			case <-release:
+				return v, nil
+			default:
+				clock.Sleep(100 * time.Millisecond)
+				time.Sleep(time.Millisecond)
+			}
+		}
+		return v, nil
+	}, RetryPolicy{Timeout: 500 * time.Millisecond})
+	var res []int
+	s.ToSlice(&res)
+	if !errors.Is(s.Err(), ErrTimeout) {
+		t.Errorf("err %v, want %v", s.Err(), ErrTimeout)
+	}
+	if !reflect.DeepEqual(res, []int{1}) {
+		t.Errorf("res %v", res)
+	}
+}
+
+func TestMapWithRetryTimeoutPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("recovered %v, want boom", r)
+		}
+	}()
+	New([]int{1}).MapWithRetry(func(v interface{}) (interface{}, error) {
+		panic("boom")
+	}, RetryPolicy{Timeout: time.Minute}).Count()
+}
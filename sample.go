@@ -0,0 +1,145 @@
+package stream
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// seed draws the seed of a sampling stage on the goroutine building it. Every
+// element draws from it and its position in the source, so that the result
+// does not depend on how the source is split into ranges.
+func seed(rnd *rand.Rand) int64 {
+	if rnd == nil {
+		return rand.Int63()
+	}
+	return rnd.Int63()
+}
+
+// draw returns the random key of the n-th element reaching a sampling stage
+// while the element at index of the source is pushed, n counts the elements
+// a FlatMap expands one into.
+func draw(seed int64, index, n int) uint64 {
+	return mix(mix(uint64(seed)+uint64(index)*0x9e3779b97f4a7c15) + uint64(n)*0x9e3779b97f4a7c15)
+}
+
+// mix is the finalizer of SplitMix64.
+func mix(x uint64) uint64 {
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// drawer numbers the elements reaching a sampling stage of a range.
+type drawer struct {
+	span  *span
+	seed  int64
+	index int
+	n     int
+}
+
+func (d *drawer) next() uint64 {
+	if d.span.index != d.index {
+		d.index, d.n = d.span.index, 0
+	}
+	d.n++
+	return draw(d.seed, d.index, d.n-1)
+}
+
+func (p *pipeline) Sample(k int, rnd *rand.Rand) Stream {
+	if k < 0 {
+		k = 0
+	}
+	base := seed(rnd)
+	return p.barrier("Sample", func() sink {
+		return &sampleSink{k: k, drawer: drawer{seed: base}}
+	})
+}
+
+func (p *pipeline) SampleFraction(fraction float64, rnd *rand.Rand) Stream {
+	base := seed(rnd)
+	return &pipeline{
+		name:          "SampleFraction",
+		previousStage: p,
+		sourceStage:   p.sourceStage,
+		forkStage: func(r *span) (func(nextStage *pipeline, v interface{}), func(nextStage *pipeline)) {
+			d := &drawer{span: r, seed: base, index: -1}
+			return func(nextStage *pipeline, v interface{}) {
+				if float64(d.next()>>11)/(1<<53) < fraction {
+					nextStage.do(nextStage.nextStage, v)
+				}
+			}, nil
+		},
+	}
+}
+
+func (p *pipeline) Shuffle(rnd *rand.Rand) Stream {
+	base := seed(rnd)
+	t := p.barrier("Shuffle", func() sink {
+		return &bufferSink{limit: -1}
+	})
+	fill := t.fill
+	t.fill = func(down *evaluation) []interface{} {
+		data := fill(down)
+		rand.New(rand.NewSource(base)).Shuffle(len(data), func(i, j int) {
+			data[i], data[j] = data[j], data[i]
+		})
+		return data
+	}
+	return t
+}
+
+type sampleItem struct {
+	key uint64
+	v   interface{}
+}
+
+// sampleSink keeps the k elements of its range with the smallest random keys,
+// a uniform sample of the range, in key order. combine keeps the k smallest
+// keys of both ranges.
+type sampleSink struct {
+	drawer
+	k     int
+	items []sampleItem
+}
+
+func (s *sampleSink) setSpan(r *span) {
+	s.span = r
+	s.index = -1
+}
+
+func (s *sampleSink) accept(v interface{}) {
+	if s.k == 0 {
+		return
+	}
+	s.items = append(s.items, sampleItem{key: s.next(), v: v})
+	if len(s.items) == 2*s.k {
+		s.truncate()
+	}
+}
+
+// truncate sorts the items by key and drops all but the first k.
+func (s *sampleSink) truncate() {
+	sort.Slice(s.items, func(i, j int) bool {
+		return s.items[i].key < s.items[j].key
+	})
+	if len(s.items) > s.k {
+		s.items = s.items[:s.k]
+	}
+}
+func (s *sampleSink) end() {
+	s.truncate()
+}
+func (s *sampleSink) cancellationRequested() bool {
+	return false
+}
+func (s *sampleSink) combine(right sink) {
+	s.items = append(s.items, right.(*sampleSink).items...)
+	s.truncate()
+}
+func (s *sampleSink) buffer() []interface{} {
+	data := make([]interface{}, len(s.items))
+	for i, item := range s.items {
+		data[i] = item.v
+	}
+	return data
+}
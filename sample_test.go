@@ -0,0 +1,85 @@
+package stream
+
+import (
+	"math/rand"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	ints := createInts(1000)
+	for _, parallel := range []bool{false, true} {
+		var first, second []int
+		stream(ints, parallel).Sample(10, rand.New(rand.NewSource(1))).ToSlice(&first)
+		stream(ints, parallel).Sample(10, rand.New(rand.NewSource(1))).ToSlice(&second)
+		if len(first) != 10 || !reflect.DeepEqual(first, second) {
+			t.Errorf("parallel=%v: samples %v and %v", parallel, first, second)
+		}
+	}
+
+	counts := make([]int, 10)
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < 2000; i++ {
+		var sample []int
+		Parallel(createInts(10)).Sample(2, rnd).ToSlice(&sample)
+		for _, v := range sample {
+			counts[v]++
+		}
+	}
+	for v, count := range counts {
+		if count < 300 || count > 500 {
+			t.Errorf("%d sampled %d times, want about 400", v, count)
+		}
+	}
+}
+
+func TestSampleFraction(t *testing.T) {
+	ints := createInts(10000)
+	for _, parallel := range []bool{false, true} {
+		var first, second []int
+		stream(ints, parallel).SampleFraction(0.1, rand.New(rand.NewSource(1))).ToSlice(&first)
+		stream(ints, parallel).SampleFraction(0.1, rand.New(rand.NewSource(1))).ToSlice(&second)
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("parallel=%v: samples differ", parallel)
+		}
+		if len(first) < 900 || len(first) > 1100 {
+			t.Errorf("parallel=%v: sampled %d, want about 1000", parallel, len(first))
+		}
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	ints := createInts(100)
+	var first, second []int
+	Parallel(ints).Shuffle(rand.New(rand.NewSource(1))).ToSlice(&first)
+	New(ints).Shuffle(rand.New(rand.NewSource(1))).ToSlice(&second)
+	if !reflect.DeepEqual(first, second) || reflect.DeepEqual(first, ints) {
+		t.Errorf("shuffled %v and %v", first, second)
+	}
+	sort.Ints(first)
+	for i, v := range first {
+		if i != v {
+			t.Fatalf("shuffled %v", first)
+		}
+	}
+}
+
+func TestSampleGOMAXPROCS(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	ints := createInts(10000)
+	sample := func(s Stream) (sampled, fraction []int) {
+		s.Sample(10, rand.New(rand.NewSource(1))).ToSlice(&sampled)
+		s.SampleFraction(0.1, rand.New(rand.NewSource(1))).ToSlice(&fraction)
+		return sampled, fraction
+	}
+	wantSampled, wantFraction := sample(New(ints))
+	for _, procs := range []int{1, 3, 8} {
+		runtime.GOMAXPROCS(procs)
+		sampled, fraction := sample(Parallel(ints))
+		if !reflect.DeepEqual(sampled, wantSampled) || !reflect.DeepEqual(fraction, wantFraction) {
+			t.Errorf("GOMAXPROCS=%d: samples differ from the sequential ones", procs)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package stream
+
+import (
+	"runtime"
+	"sync"
+)
+
+func (p *pipeline) Scan(initial interface{}, accumulator BiFunction) Stream {
+	nilCheck(accumulator)
+	if p.sourceStage.parallel {
+		t := p.barrier("Scan", func() sink {
+			return &bufferSink{limit: -1}
+		})
+		fill := t.fill
+		t.fill = func(down *evaluation) []interface{} {
+			return scan(fill(down), initial, accumulator)
+		}
+		return t
+	}
+	return &pipeline{
+		name:          "Scan",
+		previousStage: p,
+		sourceStage:   p.sourceStage,
+		forkStage: func(r *span) (func(nextStage *pipeline, v interface{}), func(nextStage *pipeline)) {
+			acc := initial
+			return func(nextStage *pipeline, v interface{}) {
+				acc = accumulator(acc, v)
+				nextStage.do(nextStage.nextStage, acc)
+			}, nil
+		},
+	}
+}
+
+// scan replaces data by its running accumulation in two parallel passes: the
+// first reduces every chunk, the second accumulates every chunk starting from
+// the accumulation of the chunks before it.
+func scan(data []interface{}, initial interface{}, accumulator BiFunction) []interface{} {
+	chunks := 4 * runtime.GOMAXPROCS(0)
+	grain := (len(data) + chunks - 1) / chunks
+	if grain < 1 {
+		grain = 1
+	}
+	var bounds []int
+	for start := 0; start < len(data); start += grain {
+		bounds = append(bounds, start)
+	}
+	bounds = append(bounds, len(data))
+	parallel := func(f func(chunk []interface{}, i int)) {
+		waitGroup := sync.WaitGroup{}
+		for i := 0; i+1 < len(bounds); i++ {
+			waitGroup.Add(1)
+			go func(i int) {
+				defer waitGroup.Done()
+				f(data[bounds[i]:bounds[i+1]], i)
+			}(i)
+		}
+		waitGroup.Wait()
+	}
+	totals := make([]interface{}, len(bounds)-1)
+	parallel(func(chunk []interface{}, i int) {
+		total := chunk[0]
+		for _, v := range chunk[1:] {
+			total = accumulator(total, v)
+		}
+		totals[i] = total
+	})
+	for i := range totals {
+		totals[i], initial = initial, accumulator(initial, totals[i])
+	}
+	parallel(func(chunk []interface{}, i int) {
+		acc := totals[i]
+		for j, v := range chunk {
+			acc = accumulator(acc, v)
+			chunk[j] = acc
+		}
+	})
+	return data
+}
@@ -0,0 +1,44 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	sum := func(t, u interface{}) interface{} {
+		return t.(int) + u.(int)
+	}
+	max := func(t, u interface{}) interface{} {
+		if u.(int) > t.(int) {
+			return u
+		}
+		return t
+	}
+	ints := createInts(1000)
+	for _, parallel := range []bool{false, true} {
+		var totals, maxima []int
+		stream(ints, parallel).Scan(10, sum).ToSlice(&totals)
+		stream(ints, parallel).Scan(-1, max).ToSlice(&maxima)
+		total, runningMax := 10, -1
+		for i, v := range ints {
+			total += v
+			if v > runningMax {
+				runningMax = v
+			}
+			if totals[i] != total || maxima[i] != runningMax {
+				t.Fatalf("parallel=%v: %d: total %d, max %d", parallel, i, totals[i], maxima[i])
+			}
+		}
+	}
+	var limited []int
+	New([]int{1, 2, 3, 4}).Scan(0, sum).Limit(3).ToSlice(&limited)
+	if !reflect.DeepEqual(limited, []int{1, 3, 6}) {
+		t.Errorf("limited %v", limited)
+	}
+	var empty []int
+	Parallel([]int{}).Scan(0, sum).ToSlice(&empty)
+	if len(empty) != 0 {
+		t.Errorf("empty %v", empty)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build go1.23
+
+package stream
+
+import "iter"
+
+// FromSeq returns a sequential stream over seq. The stream reads seq lazily
+// and stops it once the terminal operation needs no more elements.
+func FromSeq[V any](seq iter.Seq[V]) Stream {
+	nilCheck(seq)
+	return Generate(func(yield func(v interface{}) bool) {
+		for v := range seq {
+			if !yield(v) {
+				return
+			}
+		}
+	})
+}
+
+// FromSeq2 returns a sequential stream of KeyValue elements over seq.
+func FromSeq2[K, V any](seq iter.Seq2[K, V]) Stream {
+	nilCheck(seq)
+	return Generate(func(yield func(v interface{}) bool) {
+		for k, v := range seq {
+			if !yield(KeyValue{Key: k, Value: v}) {
+				return
+			}
+		}
+	})
+}
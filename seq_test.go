@@ -0,0 +1,95 @@
+//go:build go1.23
+
+package stream
+
+import (
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestFromSeq(t *testing.T) {
+	students := createStudents()
+	names := FromSeq(slices.Values(students)).Map(func(v interface{}) interface{} {
+		return v.(student).name
+	}).Distinct(func(i, j interface{}) bool {
+		return i == j
+	})
+	for name := range names.All() {
+		fmt.Println(name)
+	}
+
+	ages := map[string]int{"Tom": 16, "Kate": 22, "Lucy": 19}
+	count := FromSeq2(maps.All(ages)).Filter(func(v interface{}) bool {
+		return v.(KeyValue).Value.(int) > 18
+	}).Count()
+	if count != 2 {
+		t.Errorf("count %d, want 2", count)
+	}
+}
+
+func TestAllBreak(t *testing.T) {
+	naturals := func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	var res []int
+	for v := range FromSeq(naturals).Filter(func(v interface{}) bool {
+		return v.(int)%2 == 0
+	}).All() {
+		if len(res) == 3 {
+			break
+		}
+		res = append(res, v.(int))
+	}
+	if !slices.Equal(res, []int{0, 2, 4}) {
+		t.Errorf("res %v, want [0 2 4]", res)
+	}
+
+	it := FromSeq(naturals).Iterator()
+	if it.Next() != 0 || it.Next() != 1 {
+		t.Error("iterator over an infinite sequence")
+	}
+
+	first := FromSeq(naturals).FindFirst(func(v interface{}) bool {
+		return v.(int) > 10
+	})
+	if first != 11 {
+		t.Errorf("first %v, want 11", first)
+	}
+}
+
+func TestFlatMapSeq(t *testing.T) {
+	var res []int
+	New([]int{1, 2}).FlatMap(func(v interface{}) interface{} {
+		return slices.Values([]int{v.(int), v.(int) * 10})
+	}).FlatMap(func(v interface{}) interface{} {
+		var seq iter.Seq[any] = slices.Values([]any{v})
+		return seq
+	}).ToSlice(&res)
+	if !slices.Equal(res, []int{1, 10, 2, 20}) {
+		t.Errorf("res %v, want [1 10 2 20]", res)
+	}
+
+	stopped := false
+	first := New([]int{1}).FlatMap(func(v interface{}) interface{} {
+		return iter.Seq[int](func(yield func(int) bool) {
+			for i := 0; ; i++ {
+				if !yield(i) {
+					stopped = true
+					return
+				}
+			}
+		})
+	}).FindFirst(func(v interface{}) bool {
+		return v.(int) > 3
+	})
+	if first != 4 || !stopped {
+		t.Errorf("first %v, stopped %v", first, stopped)
+	}
+}
@@ -0,0 +1,86 @@
+package stream
+
+func (p *pipeline) Union(other Stream, key Function) Stream {
+	nilCheck(other)
+	nilCheck(key)
+	right := other.(*pipeline)
+	t := p.barrierSource("Union")
+	t.fill = func(down *evaluation) []interface{} {
+		newSink := func() sink {
+			return &keySink{bufferSink: bufferSink{limit: -1}, key: key, seen: make(map[interface{}]bool)}
+		}
+		left, err := p.collectFor(down, "Union", newSink)
+		s := left.(*keySink)
+		r, rightErr := right.collectFor(down, "Union", newSink)
+		s.combine(r)
+		if err == nil {
+			err = rightErr
+		}
+		t.fillErr = err
+		return s.buffer()
+	}
+	return t
+}
+
+func (p *pipeline) Intersect(other Stream, key Function) Stream {
+	return p.setOp("Intersect", other, key, true)
+}
+
+func (p *pipeline) Except(other Stream, key Function) Stream {
+	return p.setOp("Except", other, key, false)
+}
+
+// setOp keeps the first element of every key of p that is among the keys of
+// other if in is set, or not among them otherwise.
+func (p *pipeline) setOp(name string, other Stream, key Function, in bool) Stream {
+	nilCheck(other)
+	nilCheck(key)
+	index := &joinIndex{other: other.(*pipeline), key: key}
+	t := p.barrierSource(name)
+	t.fill = func(down *evaluation) []interface{} {
+		index.build(down)
+		s, err := p.collectFor(down, name, func() sink {
+			return &keySink{bufferSink: bufferSink{limit: -1}, key: key, seen: make(map[interface{}]bool), keep: func(k interface{}) bool {
+				_, ok := index.positions[k]
+				return ok == in
+			}}
+		})
+		if err == nil {
+			err = index.err
+		}
+		t.fillErr = err
+		return s.(buffered).buffer()
+	}
+	return t
+}
+
+// keySink keeps the first element of every key of its range, and on combine
+// the elements of the following range with keys it has not seen.
+type keySink struct {
+	bufferSink
+	key  Function
+	keep func(k interface{}) bool
+	seen map[interface{}]bool
+	keys []interface{}
+}
+
+func (k *keySink) accept(v interface{}) {
+	key := k.key(v)
+	if k.keep != nil && !k.keep(key) {
+		return
+	}
+	k.add(key, v)
+}
+func (k *keySink) add(key, v interface{}) {
+	if !k.seen[key] {
+		k.seen[key] = true
+		k.keys = append(k.keys, key)
+		k.data = append(k.data, v)
+	}
+}
+func (k *keySink) combine(right sink) {
+	r := right.(*keySink)
+	for i, v := range r.data {
+		k.add(r.keys[i], v)
+	}
+}
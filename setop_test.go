@@ -0,0 +1,29 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetOps(t *testing.T) {
+	yesterday := []string{"a1", "b1", "c1", "d1", "b2"}
+	today := []string{"b3", "e1", "a2", "f1", "e2"}
+	key := func(v interface{}) interface{} {
+		return v.(string)[:1]
+	}
+	for _, parallel := range []bool{false, true} {
+		var union, intersect, except []string
+		stream(yesterday, parallel).Union(stream(today, parallel), key).ToSlice(&union)
+		stream(yesterday, parallel).Intersect(New(today), key).ToSlice(&intersect)
+		stream(yesterday, parallel).Except(New(today), key).ToSlice(&except)
+		if !reflect.DeepEqual(union, []string{"a1", "b1", "c1", "d1", "e1", "f1"}) {
+			t.Errorf("parallel=%v: union %v", parallel, union)
+		}
+		if !reflect.DeepEqual(intersect, []string{"a1", "b1"}) {
+			t.Errorf("parallel=%v: intersect %v", parallel, intersect)
+		}
+		if !reflect.DeepEqual(except, []string{"c1", "d1"}) {
+			t.Errorf("parallel=%v: except %v", parallel, except)
+		}
+	}
+}
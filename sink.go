@@ -0,0 +1,281 @@
+package stream
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// sink is the per-range state of a terminal or barrier stage. Sequential
+// evaluation feeds every element into one sink, parallel evaluation gives each
+// range its own sink and merges them in encounter order.
+type sink interface {
+	accept(v interface{})
+	// end is called once the range has been fully pushed into the sink.
+	end()
+	// cancellationRequested reports whether the sink needs no more elements.
+	cancellationRequested() bool
+	// combine merges right, the sink of the range following this one, into
+	// this sink.
+	combine(right sink)
+}
+
+// spanSink is implemented by sinks that need to know the range they collect
+// and the position of the elements in the source.
+type spanSink interface {
+	setSpan(r *span)
+}
+
+// doSink adapts a terminal stage that only has a do function.
+type doSink struct {
+	do func(nextStage *pipeline, v interface{})
+}
+
+func (d *doSink) accept(v interface{}) {
+	d.do(nil, v)
+}
+func (d *doSink) end() {
+}
+func (d *doSink) cancellationRequested() bool {
+	return false
+}
+func (d *doSink) combine(right sink) {
+}
+
+// buffered is implemented by the sinks of barrier stages.
+type buffered interface {
+	buffer() []interface{}
+}
+
+// bufferSink keeps the elements of its range in encounter order, up to limit
+// elements when limit is not negative.
+type bufferSink struct {
+	data  []interface{}
+	limit int
+}
+
+func (b *bufferSink) accept(v interface{}) {
+	if !b.cancellationRequested() {
+		b.data = append(b.data, v)
+	}
+}
+func (b *bufferSink) end() {
+}
+func (b *bufferSink) buffer() []interface{} {
+	return b.data
+}
+func (b *bufferSink) cancellationRequested() bool {
+	return b.limit >= 0 && len(b.data) >= b.limit
+}
+func (b *bufferSink) combine(right sink) {
+	b.data = append(b.data, right.(*bufferSink).data...)
+	if b.limit >= 0 && len(b.data) > b.limit {
+		b.data = b.data[:b.limit]
+	}
+}
+
+// anyLimitSink keeps the elements of its range while the sinks of all the
+// ranges took less than limit, taken is shared by them.
+type anyLimitSink struct {
+	bufferSink
+	limit int64
+	taken *int64
+}
+
+func (a *anyLimitSink) accept(v interface{}) {
+	if atomic.AddInt64(a.taken, 1) <= a.limit {
+		a.data = append(a.data, v)
+	}
+}
+func (a *anyLimitSink) cancellationRequested() bool {
+	return atomic.LoadInt64(a.taken) >= a.limit
+}
+func (a *anyLimitSink) combine(right sink) {
+	a.data = append(a.data, right.(*anyLimitSink).data...)
+}
+
+// sortSink stable sorts its range on end and merges sorted ranges on combine.
+type sortSink struct {
+	bufferSink
+	comparator Comparator
+}
+
+func (s *sortSink) end() {
+	sort.Stable(&sortData{data: s.data, comparator: s.comparator})
+}
+func (s *sortSink) combine(right sink) {
+	left, r := s.data, right.(*sortSink).data
+	merged := make([]interface{}, 0, len(left)+len(r))
+	i, j := 0, 0
+	for i < len(left) && j < len(r) {
+		if s.comparator(r[j], left[i]) {
+			merged = append(merged, r[j])
+			j++
+		} else {
+			merged = append(merged, left[i])
+			i++
+		}
+	}
+	merged = append(merged, left[i:]...)
+	s.data = append(merged, r[j:]...)
+}
+
+// distinctSink keeps the first of the elements its comparator considers equal.
+type distinctSink struct {
+	bufferSink
+	comparator Comparator
+}
+
+func (d *distinctSink) accept(v interface{}) {
+	if !d.contains(v) {
+		d.data = append(d.data, v)
+	}
+}
+func (d *distinctSink) contains(v interface{}) bool {
+	for _, tmp := range d.data {
+		if d.comparator(tmp, v) {
+			return true
+		}
+	}
+	return false
+}
+func (d *distinctSink) combine(right sink) {
+	for _, v := range right.(*distinctSink).data {
+		d.accept(v)
+	}
+}
+
+type groupSink struct {
+	function Function
+	res      map[interface{}][]interface{}
+}
+
+func (g *groupSink) accept(v interface{}) {
+	out := g.function(v)
+	if out != nil {
+		g.res[out] = append(g.res[out], v)
+	}
+}
+func (g *groupSink) end() {
+}
+func (g *groupSink) cancellationRequested() bool {
+	return false
+}
+func (g *groupSink) combine(right sink) {
+	for k, v := range right.(*groupSink).res {
+		g.res[k] = append(g.res[k], v...)
+	}
+}
+
+type reduceSink struct {
+	function BiFunction
+	res      interface{}
+	present  bool
+}
+
+func (r *reduceSink) accept(v interface{}) {
+	if !r.present {
+		r.res, r.present = v, true
+	} else {
+		r.res = r.function(r.res, v)
+	}
+}
+func (r *reduceSink) end() {
+}
+func (r *reduceSink) cancellationRequested() bool {
+	return false
+}
+func (r *reduceSink) combine(right sink) {
+	if rs := right.(*reduceSink); rs.present {
+		r.accept(rs.res)
+	}
+}
+
+type countSink struct {
+	count int
+}
+
+func (c *countSink) accept(v interface{}) {
+	c.count++
+}
+func (c *countSink) end() {
+}
+func (c *countSink) cancellationRequested() bool {
+	return false
+}
+func (c *countSink) combine(right sink) {
+	c.count += right.(*countSink).count
+}
+
+// findFirstSink keeps the first match of its range, stop is shared by the
+// sinks of an unordered evaluation to stop every range at the first match.
+type findFirstSink struct {
+	predicate Predicate
+	res       interface{}
+	found     bool
+	stop      *int32
+}
+
+func (f *findFirstSink) accept(v interface{}) {
+	if !f.found && f.predicate(v) {
+		f.res, f.found = v, true
+		if f.stop != nil {
+			atomic.StoreInt32(f.stop, 1)
+		}
+	}
+}
+func (f *findFirstSink) end() {
+}
+func (f *findFirstSink) cancellationRequested() bool {
+	return f.found || f.stop != nil && atomic.LoadInt32(f.stop) == 1
+}
+func (f *findFirstSink) combine(right sink) {
+	if rs := right.(*findFirstSink); !f.found && rs.found {
+		f.res, f.found = rs.res, true
+	}
+}
+
+// matchSink stops every range once one of them matched, stop is shared by all
+// the sinks of one evaluation.
+type matchSink struct {
+	predicate        Predicate
+	flag             bool
+	entered, matched bool
+	stop             *int32
+}
+
+func (m *matchSink) accept(v interface{}) {
+	m.entered = true
+	if m.predicate(v) == m.flag {
+		m.matched = true
+		atomic.StoreInt32(m.stop, 1)
+	}
+}
+func (m *matchSink) end() {
+}
+func (m *matchSink) cancellationRequested() bool {
+	return atomic.LoadInt32(m.stop) == 1
+}
+func (m *matchSink) combine(right sink) {
+	rs := right.(*matchSink)
+	m.entered = m.entered || rs.entered
+	m.matched = m.matched || rs.matched
+}
+
+// yieldSink hands every element to the body of a range-over-func loop.
+type yieldSink struct {
+	yield   func(v interface{}) bool
+	stopped bool
+}
+
+func (y *yieldSink) accept(v interface{}) {
+	if !y.stopped && !y.yield(v) {
+		y.stopped = true
+	}
+}
+func (y *yieldSink) end() {
+}
+func (y *yieldSink) cancellationRequested() bool {
+	return y.stopped
+}
+func (y *yieldSink) combine(right sink) {
+}
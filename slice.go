@@ -0,0 +1,151 @@
+package stream
+
+import "reflect"
+
+// toInterfaces copies arr into a new []interface{}. A []interface{} is used as
+// is, so it must not be modified while a stream over it is evaluated.
+func toInterfaces(arr interface{}) []interface{} {
+	switch arr := arr.(type) {
+	case []interface{}:
+		return arr
+	case []int:
+		data := make([]interface{}, len(arr))
+		for i, v := range arr {
+			data[i] = v
+		}
+		return data
+	case []int64:
+		data := make([]interface{}, len(arr))
+		for i, v := range arr {
+			data[i] = v
+		}
+		return data
+	case []float64:
+		data := make([]interface{}, len(arr))
+		for i, v := range arr {
+			data[i] = v
+		}
+		return data
+	case []string:
+		data := make([]interface{}, len(arr))
+		for i, v := range arr {
+			data[i] = v
+		}
+		return data
+	}
+	arrValue := reflect.ValueOf(arr)
+	kindCheck(arrValue)
+	data := make([]interface{}, arrValue.Len())
+	for i := range data {
+		data[i] = arrValue.Index(i).Interface()
+	}
+	return data
+}
+
+// yieldElements passes the elements of the slice or array arr to yield until
+// it returns false, without copying arr.
+func yieldElements(arr interface{}, yield func(v interface{}) bool) {
+	switch arr := arr.(type) {
+	case []interface{}:
+		each(arr)(yield)
+		return
+	case []int:
+		for _, v := range arr {
+			if !yield(v) {
+				return
+			}
+		}
+		return
+	case []int64:
+		for _, v := range arr {
+			if !yield(v) {
+				return
+			}
+		}
+		return
+	case []float64:
+		for _, v := range arr {
+			if !yield(v) {
+				return
+			}
+		}
+		return
+	case []string:
+		for _, v := range arr {
+			if !yield(v) {
+				return
+			}
+		}
+		return
+	}
+	arrValue := reflect.ValueOf(arr)
+	kindCheck(arrValue)
+	if arrValue.Kind() == reflect.Ptr {
+		arrValue = arrValue.Elem()
+	}
+	for i := 0; i < arrValue.Len(); i++ {
+		if !yield(arrValue.Index(i).Interface()) {
+			return
+		}
+	}
+}
+
+// appendTo appends the non nil elements of data to the slice targetSlice
+// points to.
+func appendTo(targetSlice interface{}, data []interface{}) {
+	switch target := targetSlice.(type) {
+	case *[]interface{}:
+		for _, v := range data {
+			if v != nil {
+				*target = append(*target, v)
+			}
+		}
+		return
+	case *[]int:
+		for _, v := range data {
+			if v != nil {
+				*target = append(*target, v.(int))
+			}
+		}
+		return
+	case *[]int64:
+		for _, v := range data {
+			if v != nil {
+				*target = append(*target, v.(int64))
+			}
+		}
+		return
+	case *[]float64:
+		for _, v := range data {
+			if v != nil {
+				*target = append(*target, v.(float64))
+			}
+		}
+		return
+	case *[]string:
+		for _, v := range data {
+			if v != nil {
+				*target = append(*target, v.(string))
+			}
+		}
+		return
+	}
+	sliceValue := reflect.ValueOf(targetSlice).Elem()
+	n := 0
+	for _, v := range data {
+		if v != nil {
+			n++
+		}
+	}
+	oldLen := sliceValue.Len()
+	grown := reflect.MakeSlice(sliceValue.Type(), oldLen+n, oldLen+n)
+	reflect.Copy(grown, sliceValue)
+	i := oldLen
+	for _, v := range data {
+		if v != nil {
+			grown.Index(i).Set(reflect.ValueOf(v))
+			i++
+		}
+	}
+	sliceValue.Set(grown)
+}
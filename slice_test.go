@@ -0,0 +1,50 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToSliceFastPath(t *testing.T) {
+	var ints []int
+	New([]interface{}{1, nil, 2}).ToSlice(&ints)
+	if !reflect.DeepEqual(ints, []int{1, 2}) {
+		t.Errorf("ints %v", ints)
+	}
+
+	students := createStudents()
+	studentArray := []student{{id: 0}}
+	Parallel(students).ToSlice(&studentArray)
+	if len(studentArray) != len(students)+1 || studentArray[1].id != students[0].id {
+		t.Errorf("students %v", studentArray)
+	}
+
+	var names [3]string
+	var nameArray []string
+	New(names).ToSlice(&nameArray)
+	if len(nameArray) != 3 {
+		t.Errorf("names %v", nameArray)
+	}
+}
+
+func BenchmarkNew(b *testing.B) {
+	ints := createInts(100000)
+	for i := 0; i < b.N; i++ {
+		New(ints)
+	}
+}
+
+func BenchmarkNewReflect(b *testing.B) {
+	students := make([]student, 100000)
+	for i := 0; i < b.N; i++ {
+		New(students)
+	}
+}
+
+func BenchmarkToSlice(b *testing.B) {
+	ints := createInts(100000)
+	for i := 0; i < b.N; i++ {
+		var res []int
+		New(ints).ToSlice(&res)
+	}
+}
@@ -1,16 +1,24 @@
 package stream
 
 import (
+	"context"
+	"io"
+	"math/rand"
 	"reflect"
-	"sort"
 	"sync"
+	"sync/atomic"
 )
 
-//Stream 实现javastream api部分功能
+// Stream 实现javastream api部分功能
 type Stream interface {
 	//过滤
 	Filter(predicate Predicate) Stream
 	Map(function Function) Stream
+	// FlatMap passes on the elements of what function returns: a slice, an
+	// array, the KeyValue pairs of a map, what a channel receives until it
+	// is closed, a Stream, an Iterator or a func(yield func(v T) bool)
+	// generator such as an iter.Seq. The elements are passed on one at a time
+	// and only as long as the downstream stages need more.
 	FlatMap(function Function) Stream
 	ForEach(consumer Consumer)
 	Peek(consumer Consumer) Stream
@@ -18,6 +26,28 @@ type Stream interface {
 	Skip(n int) Stream
 	Sorted(comparator Comparator) Stream
 	Distinct(comparator Comparator) Stream
+	// DistinctApprox drops the elements whose key was seen before according
+	// to a Bloom filter sized for expectedN keys, so that about fpRate of the
+	// first occurrences are dropped too. It is not a barrier, and in a
+	// parallel stream any of the duplicates may be the one passed on, or
+	// rarely two of them when two workers add the key at once. Every
+	// evaluation of the stream starts with an empty filter.
+	DistinctApprox(key Function, expectedN int, fpRate float64) Stream
+	// Sample keeps k elements chosen uniformly at random, SampleFraction
+	// keeps every element with probability p and Shuffle puts the elements
+	// in random order. The draws depend on the position of the elements in
+	// the source, so the same rnd gives the same result for a sequential or
+	// parallel stream and any GOMAXPROCS, unless a stage upstream such as
+	// MapConcurrent passes elements on later. A nil rnd uses the math/rand
+	// source.
+	Sample(k int, rnd *rand.Rand) Stream
+	SampleFraction(p float64, rnd *rand.Rand) Stream
+	Shuffle(rnd *rand.Rand) Stream
+	// Scan passes on every partial result of accumulating the elements
+	// starting from initial. In a parallel stream accumulator must be
+	// associative, Scan is then a barrier accumulating the ranges in two
+	// passes.
+	Scan(initial interface{}, accumulator BiFunction) Stream
 	AllMatch(predicate Predicate) bool
 	AnyMatch(predicate Predicate) bool
 	NoneMatch(predicate Predicate) bool
@@ -25,19 +55,124 @@ type Stream interface {
 	Reduce(function BiFunction) interface{}
 	ToSlice(targetSlice interface{})
 	MaxMin(comparator Comparator) interface{}
+	// Quantiles returns the exact qs quantiles of the keys, interpolated
+	// between the closest ranks.
+	Quantiles(key FloatFunction, qs ...float64) []float64
+	// Histogram counts the keys below bounds[0], in every
+	// [bounds[i-1], bounds[i]) and from bounds[len(bounds)-1] on.
+	Histogram(key FloatFunction, bounds []float64) []int
+	// Digest summarizes the keys in a t-digest of fixed size.
+	Digest(key FloatFunction, compression float64) *TDigest
+	// ApproxCountDistinct estimates the number of distinct keys with a
+	// HyperLogLog sketch of 2^precision registers, the standard error is
+	// about 1.04/sqrt(2^precision).
+	ApproxCountDistinct(key Function, precision int) int
 	FindFirst(predicate Predicate) interface{}
 	Group(function Function) map[interface{}][]interface{}
+	Iterator() Iterator
+	// TopK returns the first k elements in comparator order, the same as
+	// Sorted(comparator).Limit(k), which is evaluated as TopK.
+	TopK(k int, comparator Comparator) []interface{}
+	// BottomK returns the last k elements in comparator order.
+	BottomK(k int, comparator Comparator) []interface{}
+	// SortedExternal sorts like Sorted, spilling sorted runs to temporary
+	// files and merging them lazily into the downstream stages.
+	SortedExternal(comparator Comparator, opts SpillOptions) Stream
+	Explain() *Plan
+	// Observe returns the stream reporting to observer every evaluation of
+	// it and of the streams built on it, including the evaluations filling
+	// the barriers upstream of it. A barrier is filled once, by the first
+	// evaluation reading it.
+	Observe(observer Observer) Stream
+	// Label returns the stream naming the pipeline for the pprof labels of
+	// the callbacks of its evaluations, including the evaluations filling
+	// the barriers upstream of it, the labels of ctx are kept.
+	Label(ctx context.Context, pipeline string) Stream
+	// Named returns the stream naming its last stage for its pprof labels.
+	Named(stage string) Stream
+	// MapConcurrent calls function on up to n elements at a time, per worker
+	// of a parallel stream, and passes the results on in encounter order.
+	MapConcurrent(n int, function Function) Stream
+	// MapConcurrentUnordered is MapConcurrent passing every result on as soon
+	// as it is ready.
+	MapConcurrentUnordered(n int, function Function) Stream
+	// WithClock returns the stream setting the clock of the time based
+	// stages added after it.
+	WithClock(clock Clock) Stream
+	// Throttle passes on rate elements per second, and up to burst at once,
+	// shared by all the workers of a parallel stream.
+	Throttle(rate float64, burst int) Stream
+	// MapWithRetry calls function until it succeeds or policy gives up, the
+	// stream then stops and Err returns the last error.
+	MapWithRetry(function ErrFunction, policy RetryPolicy) Stream
+	// Err returns the error that stopped the last evaluation of the stream,
+	// if any.
+	Err() error
+	// Sequential and Parallel evaluate the stages added after them in that
+	// mode, the elements so far are collected first unless the mode is
+	// already the same.
+	Sequential() Stream
+	Parallel() Stream
+	// Unordered lets the stages added after it ignore the encounter order:
+	// Limit keeps any maxSize elements and FindFirst returns any match,
+	// stopping all the workers of a parallel stream at once, and
+	// MapConcurrent passes results on as soon as they are ready.
+	Unordered() Stream
+	// Then adds a custom intermediate operation, newStage creates the Stage
+	// of every range.
+	Then(newStage func() Stage) Stream
+	// Collect evaluates the stream into the Collectors newCollector creates,
+	// one per range, and returns them merged.
+	Collect(newCollector func() Collector) Collector
+	// Join passes on combiner(l, r) for every element l and every element r
+	// of other with an equal key, other is read once into a hash index.
+	Join(other Stream, leftKey, rightKey Function, combiner BiFunction) Stream
+	// LeftJoin is Join also passing on combiner(l, nil) for the elements
+	// without match.
+	LeftJoin(other Stream, leftKey, rightKey Function, combiner BiFunction) Stream
+	// FullJoin is LeftJoin followed by combiner(nil, r) for the elements of
+	// other without match.
+	FullJoin(other Stream, leftKey, rightKey Function, combiner BiFunction) Stream
+	// MergeJoin joins two streams sorted by key without indexing either,
+	// comparator orders the keys.
+	MergeJoin(other Stream, kind JoinKind, leftKey, rightKey Function, comparator Comparator, combiner BiFunction) Stream
+	// Union, Intersect and Except compare the elements by key and keep the
+	// first element of every key, in encounter order. Union passes on the
+	// elements of the stream then those of other, Intersect the elements with
+	// a key in other and Except the elements with a key not in other.
+	Union(other Stream, key Function) Stream
+	Intersect(other Stream, key Function) Stream
+	Except(other Stream, key Function) Stream
+	WriteLines(w io.Writer) error
+	WriteCSV(w io.Writer) error
+	WriteJSONLines(w io.Writer) error
+	// All returns the elements as a range-over-func iterator, breaking out
+	// of the loop stops the pipeline and its source.
+	All() func(yield func(v interface{}) bool)
+}
+
+// TerminalOp evaluates the stages from the source stage up to terminal.
+type TerminalOp interface {
+	EvaluateParallel(terminal *pipeline)
+	EvaluateSequential(terminal *pipeline)
 }
 
-type TerminalOp interface {
-	EvaluateParallel(sourceStage *pipeline)
-	EvaluateSequential(sourceStage *pipeline)
+// Iterator pulls the elements of a stream one at a time.
+type Iterator interface {
+	HasNext() bool
+	Next() interface{}
+	// Close stops an iterator that is not exhausted, the source of the stream
+	// is stopped and its resources released before Close returns. An
+	// abandoned iterator is only stopped once it is garbage collected.
+	Close()
 }
 
 type Predicate func(v interface{}) bool
 
 type Function func(v interface{}) interface{}
 
+type ErrFunction func(v interface{}) (interface{}, error)
+
 type Consumer func(v interface{})
 
 type Comparator func(i, j interface{}) bool
@@ -59,14 +194,21 @@ func (s *sortData) Less(i, j int) bool {
 	return s.comparator(s.data[i], s.data[j])
 }
 
+// ForEachOp is the engine ForkJoinOp replaced, it pushes every element of a
+// parallel stream through the stages on a goroutine of its own, so it only
+// suits stages without state.
+//
+// Deprecated: use ForkJoinOp, which every terminal operation runs on.
 type ForEachOp struct {
 }
 
-func (f ForEachOp) EvaluateParallel(sourceStage *pipeline) {
-	headStage := sourceStage.nextStage
+func (f ForEachOp) EvaluateParallel(terminal *pipeline) {
+	ev := newEvaluation(terminal, terminal.downstream)
+	headStage := chain(terminal.sourceStage, terminal, &doSink{do: terminal.do}, &span{evaluation: ev})
 	waitGroup := sync.WaitGroup{}
-	waitGroup.Add(len(sourceStage.data))
-	for _, v := range sourceStage.data {
+	data := terminal.sourceStage.elements(ev)
+	waitGroup.Add(len(data))
+	for _, v := range data {
 		data := v
 		go func() {
 			defer waitGroup.Done()
@@ -74,16 +216,21 @@ func (f ForEachOp) EvaluateParallel(sourceStage *pipeline) {
 		}()
 	}
 	waitGroup.Wait()
+	finish(headStage)
+	terminal.setErr(ev.error())
 }
 
-func (f ForEachOp) EvaluateSequential(sourceStage *pipeline) {
-	headStage := sourceStage.nextStage
-	for _, v := range sourceStage.data {
-		headStage.do(headStage.nextStage, v)
-		if sourceStage.stop {
+func (f ForEachOp) EvaluateSequential(terminal *pipeline) {
+	ev := newEvaluation(terminal, terminal.downstream)
+	headStage := chain(terminal.sourceStage, terminal, &doSink{do: terminal.do}, &span{evaluation: ev})
+	for _, v := range terminal.sourceStage.elements(ev) {
+		if headStage.cancelled() {
 			break
 		}
+		headStage.do(headStage.nextStage, v)
 	}
+	finish(headStage)
+	terminal.setErr(ev.error())
 }
 
 func Parallel(arr interface{}) Stream {
@@ -94,16 +241,29 @@ func New(arr interface{}) Stream {
 	return stream(arr, false)
 }
 
+// Generate returns a sequential stream over the elements the generator yields.
+// The generator must stop as soon as yield returns false.
+func Generate(generator func(yield func(v interface{}) bool)) Stream {
+	nilCheck(generator)
+	return generateErr("Generate", func(yield func(v interface{}) bool) error {
+		generator(yield)
+		return nil
+	})
+}
+
+// generateErr returns a sequential stream over the elements the generator
+// yields, an error it returns stops the stream and is returned by Err.
+func generateErr(name string, generator func(yield func(v interface{}) bool) error) *pipeline {
+	p := &pipeline{name: name, generate: func(down *evaluation, yield func(v interface{}) bool) error {
+		return generator(yield)
+	}}
+	p.sourceStage = p
+	return p
+}
+
 func stream(arr interface{}, parallel bool) Stream {
 	nilCheck(arr)
-	data := make([]interface{}, 0)
-	dataValue := reflect.ValueOf(&data).Elem()
-	arrValue := reflect.ValueOf(arr)
-	kindCheck(arrValue)
-	for i := 0; i < arrValue.Len(); i++ {
-		dataValue.Set(reflect.Append(dataValue, arrValue.Index(i)))
-	}
-	p := &pipeline{data: data, parallel: parallel}
+	p := &pipeline{name: "Source", data: toInterfaces(arr), parallel: parallel}
 	p.sourceStage = p
 	return p
 }
@@ -111,96 +271,91 @@ func stream(arr interface{}, parallel bool) Stream {
 var _ Stream = &pipeline{}
 
 type pipeline struct {
-	lock                    sync.Mutex
-	data, tmpData           []interface{}
-	previousStage           *pipeline
-	sourceStage             *pipeline
-	nextStage               *pipeline
-	parallel, entered, stop bool
-	do                      func(nextStage *pipeline, v interface{})
+	name          string
+	data          []interface{}
+	previousStage *pipeline
+	sourceStage   *pipeline
+	// nextStage is only set on the private chain of a range, the stages of
+	// a stream are shared by all its evaluations and never change.
+	nextStage *pipeline
+	// upstream is the stage a barrier evaluates to fill this source stage,
+	// sorted is the comparator of a Sorted barrier.
+	upstream *pipeline
+	// fill returns the elements of a barrier, down is the evaluation reading
+	// them first.
+	fill     func(down *evaluation) []interface{}
+	fillOnce sync.Once
+	// filled is set once fill has returned, Limit reads it concurrently.
+	filled int32
+	// fillErr is the error of the evaluation fill ran, every evaluation
+	// reading the filled source fails with it.
+	fillErr  error
+	sorted   Comparator
+	parallel bool
+	// unordered is set by Unordered and on the source stages downstream of
+	// it, see ordered.
+	unordered bool
+	do        func(nextStage *pipeline, v interface{})
+	// forkStage returns the do and end functions of a private copy of a
+	// stage that keeps state for the range of s, end passes on what the copy
+	// held back.
+	forkStage func(s *span) (func(nextStage *pipeline, v interface{}), func(nextStage *pipeline))
+	end       func(nextStage *pipeline)
+	// cancelled reports, on the private chain of a range, whether the stages
+	// from this one on need no more elements.
+	cancelled func() bool
+	// newStage creates the Stage of a range for Then.
+	newStage func() Stage
+	generate func(down *evaluation, yield func(v interface{}) bool) error
+	newSink  func() sink
+	result   sink
+	// downstream is the evaluation reading the barrier a terminal stage
+	// fills.
+	downstream *evaluation
+	// setting is set on the stages only setting up the stages and
+	// evaluations downstream of them, an evaluation skips them.
+	setting  bool
+	observer Observer
+	// label is the name a Named stage gives the stage before it,
+	// pipelineLabel and labelContext are set on Label stages.
+	label         string
+	pipelineLabel string
+	labelContext  context.Context
+	clock         Clock
+	lastErr       lastError
 }
 
 func (p *pipeline) Group(function Function) map[interface{}][]interface{} {
 	nilCheck(function)
-	res := make(map[interface{}][]interface{})
-	t := &pipeline{
-		previousStage: p,
-		sourceStage:   p.sourceStage,
-		do: func(nextStage *pipeline, v interface{}) {
-			if p.sourceStage.parallel {
-				p.lock.Lock()
-				defer p.lock.Unlock()
-			}
-			out := function(v)
-			if out != nil {
-				if value, ok := res[out]; ok {
-					value = append(value, v)
-					res[out] = value
-				} else {
-					res[out] = []interface{}{v}
-				}
-			}
-		},
-	}
-	t.evaluate(&ForEachOp{})
-	return res
+	return p.collect("Group", func() sink {
+		return &groupSink{function: function, res: make(map[interface{}][]interface{})}
+	}).(*groupSink).res
 }
 
 func (p *pipeline) FlatMap(function Function) Stream {
 	nilCheck(function)
-	t := &pipeline{
+	return &pipeline{
+		name:          "FlatMap",
 		previousStage: p,
 		sourceStage:   p.sourceStage,
 		do: func(nextStage *pipeline, v interface{}) {
-			if p.sourceStage.parallel {
-				p.lock.Lock()
-				defer p.lock.Unlock()
-			}
-			out := function(v)
-			if out != nil {
-				data := make([]interface{}, 0)
-				dataValue := reflect.ValueOf(&data).Elem()
-				arrValue := reflect.ValueOf(out)
-				kindCheck(arrValue)
-				for i := 0; i < arrValue.Len(); i++ {
-					dataValue.Set(reflect.Append(dataValue, arrValue.Index(i)))
-				}
-				p.tmpData = append(p.tmpData, data...)
-			}
+			expand(function(v), func(v interface{}) bool {
+				nextStage.do(nextStage.nextStage, v)
+				return nextStage.cancelled == nil || !nextStage.cancelled()
+			})
 		},
 	}
-	t.evaluate(&ForEachOp{})
-	t.data = p.tmpData
-	t.parallel = p.sourceStage.parallel
-	t.sourceStage = t
-	return t
-
 }
 
 func (p *pipeline) FindFirst(predicate Predicate) interface{} {
 	nilCheck(predicate)
-	t := &pipeline{
-		previousStage: p,
-		sourceStage:   p.sourceStage,
-		do: func(nextStage *pipeline, v interface{}) {
-			if p.sourceStage.parallel {
-				p.lock.Lock()
-				defer p.lock.Unlock()
-			}
-			if p.tmpData == nil {
-				match := predicate(v)
-				if match {
-					p.tmpData = append(p.tmpData, v)
-					p.sourceStage.stop = true
-				}
-			}
-		},
-	}
-	t.evaluate(&ForEachOp{})
-	if p.tmpData == nil {
-		return nil
+	var stop *int32
+	if !p.ordered() {
+		stop = new(int32)
 	}
-	return p.tmpData[0]
+	return p.collect("FindFirst", func() sink {
+		return &findFirstSink{predicate: predicate, stop: stop}
+	}).(*findFirstSink).res
 }
 
 func (p *pipeline) MaxMin(comparator Comparator) interface{} {
@@ -220,52 +375,23 @@ func (p *pipeline) ToSlice(targetSlice interface{}) {
 		panic("target slice must be a pointer")
 	}
 	kindCheck(targetValue)
-	sliceValue := reflect.Indirect(targetValue)
-	t := &pipeline{
-		previousStage: p,
-		sourceStage:   p.sourceStage,
-		do: func(nextStage *pipeline, v interface{}) {
-			if v != nil {
-				if p.sourceStage.parallel {
-					p.lock.Lock()
-					defer p.lock.Unlock()
-				}
-				sliceValue.Set(reflect.Append(sliceValue, reflect.ValueOf(v)))
-			}
-		},
-	}
-	t.evaluate(&ForEachOp{})
+	data := p.collect("ToSlice", func() sink {
+		return &bufferSink{limit: -1}
+	}).(*bufferSink).data
+	appendTo(targetSlice, data)
 }
 
 func (p *pipeline) Reduce(function BiFunction) interface{} {
 	nilCheck(function)
-	t := &pipeline{
-		previousStage: p,
-		sourceStage:   p.sourceStage,
-		do: func(nextStage *pipeline, v interface{}) {
-			if p.sourceStage.parallel {
-				p.lock.Lock()
-				defer p.lock.Unlock()
-			}
-			if p.tmpData == nil {
-				p.tmpData = append(p.tmpData, v)
-			} else {
-				res := function(p.tmpData[0], v)
-				p.tmpData[0] = res
-			}
-		},
-	}
-	t.evaluate(&ForEachOp{})
-	if p.tmpData == nil {
-		return nil
-	}
-	return p.tmpData[0]
+	return p.collect("Reduce", func() sink {
+		return &reduceSink{function: function}
+	}).(*reduceSink).res
 }
 
 func (p *pipeline) Count() int {
-	t := p.statefulStage()
-	t.evaluate(&ForEachOp{})
-	return len(p.tmpData)
+	return p.collect("Count", func() sink {
+		return &countSink{}
+	}).(*countSink).count
 }
 
 func (p *pipeline) NoneMatch(predicate Predicate) bool {
@@ -273,7 +399,7 @@ func (p *pipeline) NoneMatch(predicate Predicate) bool {
 }
 
 func (p *pipeline) AnyMatch(predicate Predicate) bool {
-	entered, stop := p.matchOps(predicate, true)
+	entered, stop := p.matchOps("AnyMatch", predicate, true)
 	if entered {
 		return stop
 	}
@@ -281,75 +407,35 @@ func (p *pipeline) AnyMatch(predicate Predicate) bool {
 }
 
 func (p *pipeline) AllMatch(predicate Predicate) bool {
-	entered, stop := p.matchOps(predicate, false)
+	entered, stop := p.matchOps("AllMatch", predicate, false)
 	if entered {
 		return !stop
 	}
 	return false
 }
 
-func (p *pipeline) matchOps(predicate Predicate, flag bool) (bool, bool) {
+func (p *pipeline) matchOps(name string, predicate Predicate, flag bool) (bool, bool) {
 	nilCheck(predicate)
-	t := &pipeline{
-		previousStage: p,
-		sourceStage:   p.sourceStage,
-		do: func(nextStage *pipeline, v interface{}) {
-			p.sourceStage.entered = true
-			match := predicate(v)
-			if !flag {
-				match = !match
-			}
-			if match {
-				p.sourceStage.stop = true
-			}
-		},
-	}
-	t.evaluate(&ForEachOp{})
-	return p.sourceStage.entered, p.sourceStage.stop
+	var stop int32
+	s := p.collect(name, func() sink {
+		return &matchSink{predicate: predicate, flag: flag, stop: &stop}
+	}).(*matchSink)
+	return s.entered, s.matched
 }
 
 func (p *pipeline) Distinct(comparator Comparator) Stream {
 	nilCheck(comparator)
-	t := &pipeline{
-		previousStage: p,
-		sourceStage:   p.sourceStage,
-		do: func(nextStage *pipeline, v interface{}) {
-			if p.sourceStage.parallel {
-				p.lock.Lock()
-				defer p.lock.Unlock()
-			}
-			if p.tmpData == nil {
-				p.tmpData = append(p.tmpData, v)
-			} else {
-				flag := true
-				for _, tmp := range p.tmpData {
-					if comparator(tmp, v) {
-						flag = false
-						break
-					}
-				}
-				if flag {
-					p.tmpData = append(p.tmpData, v)
-				}
-			}
-		},
-	}
-	t.evaluate(&ForEachOp{})
-	t.data = p.tmpData
-	t.parallel = p.sourceStage.parallel
-	t.sourceStage = t
-	return t
+	return p.barrier("Distinct", func() sink {
+		return &distinctSink{bufferSink: bufferSink{limit: -1}, comparator: comparator}
+	})
 }
 
 func (p *pipeline) Sorted(comparator Comparator) Stream {
 	nilCheck(comparator)
-	t := p.statefulStage()
-	t.evaluate(&ForEachOp{})
-	s := &sortData{data: p.tmpData, comparator: comparator}
-	sort.Sort(s)
-	t.data = p.tmpData
-	t.parallel = p.sourceStage.parallel
-	t.sourceStage = t
+	t := p.barrier("Sorted", func() sink {
+		return &sortSink{bufferSink: bufferSink{limit: -1}, comparator: comparator}
+	})
+	t.sorted = comparator
 	return t
 }
 
@@ -357,15 +443,17 @@ func (p *pipeline) Skip(n int) Stream {
 	if n < 0 {
 		n = 0
 	}
-	t := p.statefulStage()
-	t.evaluate(&ForEachOp{})
-	dataLen := len(p.tmpData)
-	if dataLen < n {
-		n = dataLen
+	t := p.barrier("Skip", func() sink {
+		return &bufferSink{limit: -1}
+	})
+	fill := t.fill
+	t.fill = func(down *evaluation) []interface{} {
+		data := fill(down)
+		if len(data) < n {
+			return nil
+		}
+		return data[n:]
 	}
-	t.data = p.tmpData[n:]
-	t.parallel = p.sourceStage.parallel
-	t.sourceStage = t
 	return t
 }
 
@@ -373,21 +461,25 @@ func (p *pipeline) Limit(maxSize int) Stream {
 	if maxSize < 0 {
 		maxSize = 0
 	}
-	t := p.statefulStage()
-	t.evaluate(&ForEachOp{})
-	dataLen := len(p.tmpData)
-	if dataLen < maxSize {
-		maxSize = dataLen
+	// only right after Sorted, a setting stage in between would be dropped
+	if p.sorted != nil && atomic.LoadInt32(&p.filled) == 0 {
+		return p.upstream.topK("TopK", maxSize, p.sorted)
 	}
-	t.data = p.tmpData[:maxSize]
-	t.parallel = p.sourceStage.parallel
-	t.sourceStage = t
-	return t
+	if !p.ordered() {
+		var taken int64
+		return p.barrier("Limit", func() sink {
+			return &anyLimitSink{bufferSink: bufferSink{limit: -1}, limit: int64(maxSize), taken: &taken}
+		})
+	}
+	return p.barrier("Limit", func() sink {
+		return &bufferSink{limit: maxSize}
+	})
 }
 
 func (p *pipeline) Peek(consumer Consumer) Stream {
 	nilCheck(consumer)
 	return &pipeline{
+		name:          "Peek",
 		previousStage: p,
 		sourceStage:   p.sourceStage,
 		do: func(nextStage *pipeline, v interface{}) {
@@ -400,6 +492,7 @@ func (p *pipeline) Peek(consumer Consumer) Stream {
 func (p *pipeline) Filter(predicate Predicate) Stream {
 	nilCheck(predicate)
 	return &pipeline{
+		name:          "Filter",
 		previousStage: p,
 		sourceStage:   p.sourceStage,
 		do: func(nextStage *pipeline, v interface{}) {
@@ -413,18 +506,20 @@ func (p *pipeline) Filter(predicate Predicate) Stream {
 func (p *pipeline) ForEach(consumer Consumer) {
 	nilCheck(consumer)
 	t := &pipeline{
+		name:          "ForEach",
 		previousStage: p,
 		sourceStage:   p.sourceStage,
 		do: func(nextStage *pipeline, v interface{}) {
 			consumer(v)
 		},
 	}
-	t.evaluate(&ForEachOp{})
+	t.evaluate(ForkJoinOp{})
 }
 
 func (p *pipeline) Map(function Function) Stream {
 	nilCheck(function)
 	return &pipeline{
+		name:          "Map",
 		previousStage: p,
 		sourceStage:   p.sourceStage,
 		do: func(nextStage *pipeline, v interface{}) {
@@ -435,31 +530,103 @@ func (p *pipeline) Map(function Function) Stream {
 
 func (p *pipeline) evaluate(op TerminalOp) {
 	nilCheck(op)
-	for headStage := p; headStage != nil && headStage.previousStage != nil; headStage = headStage.previousStage {
-		headStage.previousStage.nextStage = headStage
-	}
-
 	if p.sourceStage.parallel {
-		op.EvaluateParallel(p.sourceStage)
+		op.EvaluateParallel(p)
 	} else {
-		op.EvaluateSequential(p.sourceStage)
+		op.EvaluateSequential(p)
+	}
+	// the error of the evaluation, kept by the terminal stage, is the last
+	// one of the stream it terminates
+	p.previousStage.setErr(p.Err())
+}
+
+// source returns the elements of a source stage as a generator, an error of
+// the source fails ev.
+func (p *pipeline) source(ev *evaluation) func(yield func(v interface{}) bool) {
+	if p.generate != nil {
+		return func(yield func(v interface{}) bool) {
+			if err := p.generate(ev, yield); err != nil {
+				ev.fail(err)
+			}
+		}
+	}
+	return each(p.elements(ev))
+}
+
+// elements returns the elements of a source stage, running its generator if
+// it has one.
+func (p *pipeline) elements(ev *evaluation) []interface{} {
+	if p.fill != nil {
+		p.fillOnce.Do(func() {
+			p.data = p.fill(ev)
+			atomic.StoreInt32(&p.filled, 1)
+		})
+		if p.fillErr != nil {
+			ev.fail(p.fillErr)
+		}
+		return p.data
+	}
+	if p.generate == nil {
+		return p.data
 	}
+	var data []interface{}
+	p.source(ev)(func(v interface{}) bool {
+		data = append(data, v)
+		return true
+	})
+	return data
 }
 
-func (p *pipeline) statefulStage() *pipeline {
+func each(data []interface{}) func(yield func(v interface{}) bool) {
+	return func(yield func(v interface{}) bool) {
+		for _, v := range data {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// barrier returns a new source stage over the merged buffer of the sinks
+// newSink creates, the stages up to p are evaluated when the new source is
+// first read.
+func (p *pipeline) barrier(name string, newSink func() sink) *pipeline {
+	t := p.barrierSource(name)
+	t.fill = func(down *evaluation) []interface{} {
+		s, err := p.collectFor(down, name, newSink)
+		t.fillErr = err
+		return s.(buffered).buffer()
+	}
+	return t
+}
+
+// settingStage returns a stage downstream of p passing the elements on
+// unchanged, see setting.
+func (p *pipeline) settingStage(name string) *pipeline {
 	return &pipeline{
+		name:          name,
 		previousStage: p,
 		sourceStage:   p.sourceStage,
+		setting:       true,
 		do: func(nextStage *pipeline, v interface{}) {
-			if p.sourceStage.parallel {
-				p.lock.Lock()
-				defer p.lock.Unlock()
-			}
-			p.tmpData = append(p.tmpData, v)
+			nextStage.do(nextStage.nextStage, v)
 		},
 	}
 }
 
+// barrierSource returns a new source stage downstream of p that keeps the
+// mode of p's source stage and the order of p.
+func (p *pipeline) barrierSource(name string) *pipeline {
+	t := &pipeline{
+		name:      name,
+		upstream:  p,
+		parallel:  p.sourceStage.parallel,
+		unordered: !p.ordered(),
+	}
+	t.sourceStage = t
+	return t
+}
+
 func nilCheck(v interface{}) {
 	if v == nil {
 		panic("nil forbidden")
@@ -0,0 +1,57 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket hands out rate tokens per second, up to burst at once. A caller
+// that finds the bucket empty reserves the next token and sleeps until it is
+// due.
+type tokenBucket struct {
+	lock   sync.Mutex
+	clock  Clock
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take() {
+	b.lock.Lock()
+	now := b.clock.Now()
+	if b.last.IsZero() {
+		b.tokens = b.burst
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.lock.Unlock()
+	if wait > 0 {
+		b.clock.Sleep(wait)
+	}
+}
+
+func (p *pipeline) Throttle(rate float64, burst int) Stream {
+	if rate <= 0 {
+		panic("rate must be positive")
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	bucket := &tokenBucket{clock: p.stageClock(), rate: rate, burst: float64(burst)}
+	return &pipeline{
+		name:          "Throttle",
+		previousStage: p,
+		sourceStage:   p.sourceStage,
+		do: func(nextStage *pipeline, v interface{}) {
+			bucket.take()
+			nextStage.do(nextStage.nextStage, v)
+		},
+	}
+}
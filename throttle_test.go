@@ -0,0 +1,43 @@
+package stream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	var times []time.Duration
+	New(createInts(10)).WithClock(clock).Throttle(10, 2).ForEach(func(v interface{}) {
+		times = append(times, clock.Now().Sub(start))
+	})
+	want := []time.Duration{0, 0}
+	for i := 1; i <= 8; i++ {
+		want = append(want, time.Duration(i)*100*time.Millisecond)
+	}
+	for i := range want {
+		if times[i] != want[i] {
+			t.Errorf("element %d passed at %v, want %v", i, times[i], want[i])
+		}
+	}
+
+	count := Parallel(createInts(100)).WithClock(newFakeClock()).Throttle(1000, 10).Count()
+	if count != 100 {
+		t.Errorf("count %d, want 100", count)
+	}
+}
+
+func TestWithClockBranches(t *testing.T) {
+	base := New(createInts(3))
+	left, right := newFakeClock(), newFakeClock()
+	start := left.Now()
+	l, r := base.WithClock(left), base.WithClock(right)
+	l.Throttle(1, 1).Count()
+	r.Sorted(func(i, j interface{}) bool {
+		return i.(int) < j.(int)
+	}).Throttle(1, 1).Count()
+	if left.Now().Sub(start) != 2*time.Second || right.Now().Sub(start) != 2*time.Second {
+		t.Errorf("left clock at %v, right at %v", left.Now().Sub(start), right.Now().Sub(start))
+	}
+}
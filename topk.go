@@ -0,0 +1,125 @@
+package stream
+
+import (
+	"container/heap"
+	"sort"
+)
+
+func (p *pipeline) TopK(k int, comparator Comparator) []interface{} {
+	nilCheck(comparator)
+	// filling the barrier evaluates p, which keeps the error for p.Err
+	return p.topK("TopK", k, comparator).elements(&evaluation{})
+}
+
+func (p *pipeline) BottomK(k int, comparator Comparator) []interface{} {
+	nilCheck(comparator)
+	data := p.topK("BottomK", k, func(i, j interface{}) bool {
+		return comparator(j, i)
+	}).elements(&evaluation{})
+	res := make([]interface{}, len(data))
+	for i, v := range data {
+		res[len(data)-1-i] = v
+	}
+	return res
+}
+
+// topK returns a barrier over the first k elements in comparator order.
+func (p *pipeline) topK(name string, k int, comparator Comparator) *pipeline {
+	if k < 0 {
+		k = 0
+	}
+	return p.barrier(name, func() sink {
+		return &topKSink{k: k, comparator: comparator}
+	})
+}
+
+type topKItem struct {
+	v   interface{}
+	seq int
+}
+
+// topKSink keeps the first k elements of its range in a heap whose root is the
+// last of them, equal elements are kept in encounter order. On end the heap is
+// sorted, so that combine merges two sorted ranges.
+type topKSink struct {
+	k          int
+	comparator Comparator
+	items      []topKItem
+	seq        int
+}
+
+func (t *topKSink) before(a, b topKItem) bool {
+	if t.comparator(a.v, b.v) {
+		return true
+	}
+	if t.comparator(b.v, a.v) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (t *topKSink) Len() int {
+	return len(t.items)
+}
+func (t *topKSink) Swap(i, j int) {
+	t.items[i], t.items[j] = t.items[j], t.items[i]
+}
+func (t *topKSink) Less(i, j int) bool {
+	return t.before(t.items[j], t.items[i])
+}
+func (t *topKSink) Push(x interface{}) {
+	t.items = append(t.items, x.(topKItem))
+}
+func (t *topKSink) Pop() interface{} {
+	item := t.items[len(t.items)-1]
+	t.items = t.items[:len(t.items)-1]
+	return item
+}
+
+func (t *topKSink) accept(v interface{}) {
+	item := topKItem{v: v, seq: t.seq}
+	t.seq++
+	if len(t.items) < t.k {
+		heap.Push(t, item)
+	} else if t.k > 0 && t.before(item, t.items[0]) {
+		t.items[0] = item
+		heap.Fix(t, 0)
+	}
+}
+func (t *topKSink) end() {
+	sort.Slice(t.items, func(i, j int) bool {
+		return t.before(t.items[i], t.items[j])
+	})
+}
+func (t *topKSink) cancellationRequested() bool {
+	return false
+}
+func (t *topKSink) combine(right sink) {
+	left, r := t.items, right.(*topKSink).items
+	size := len(left) + len(r)
+	if size > t.k {
+		size = t.k
+	}
+	merged := make([]topKItem, 0, size)
+	i, j := 0, 0
+	for len(merged) < t.k && (i < len(left) || j < len(r)) {
+		if j == len(r) || i < len(left) && !t.comparator(r[j].v, left[i].v) {
+			merged = append(merged, left[i])
+			i++
+		} else {
+			merged = append(merged, r[j])
+			j++
+		}
+	}
+	for i := range merged {
+		merged[i].seq = i
+	}
+	t.items = merged
+}
+func (t *topKSink) buffer() []interface{} {
+	data := make([]interface{}, len(t.items))
+	for i, item := range t.items {
+		data[i] = item.v
+	}
+	return data
+}
@@ -0,0 +1,97 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTopK(t *testing.T) {
+	ints := createInts(1000)
+	less := func(i, j interface{}) bool {
+		return i.(int)%100 < j.(int)%100
+	}
+	var all []interface{}
+	New(ints).Sorted(less).ToSlice(&all)
+
+	for _, parallel := range []bool{false, true} {
+		top := stream(ints, parallel).TopK(25, less)
+		if !reflect.DeepEqual(top, all[:25]) {
+			t.Errorf("parallel=%v: top %v, want %v", parallel, top, all[:25])
+		}
+		bottom := stream(ints, parallel).BottomK(3, less)
+		for _, v := range bottom {
+			if v.(int)%100 != 99 {
+				t.Errorf("parallel=%v: bottom %v", parallel, bottom)
+			}
+		}
+
+		limited := stream(ints, parallel).Sorted(less).Limit(25)
+		if kind := limited.Explain().Stages[1].Kind; kind != "TopK" {
+			t.Errorf("Sorted followed by Limit evaluated as %s", kind)
+		}
+		var res []interface{}
+		limited.ToSlice(&res)
+		if !reflect.DeepEqual(res, all[:25]) {
+			t.Errorf("parallel=%v: limited %v, want %v", parallel, res, all[:25])
+		}
+	}
+
+	if top := New(ints).TopK(0, less); len(top) != 0 {
+		t.Errorf("top %v", top)
+	}
+
+	var unlimited []interface{}
+	Parallel(ints).Sorted(less).Limit(1 << 40).ToSlice(&unlimited)
+	if !reflect.DeepEqual(unlimited, all) {
+		t.Errorf("unlimited %v, want %v", unlimited, all)
+	}
+}
+
+func TestSortedLimitSettings(t *testing.T) {
+	less := func(i, j interface{}) bool {
+		return i.(int) < j.(int)
+	}
+	collector := &StatsCollector{}
+	count := New(createInts(10)).Sorted(less).Observe(collector).Limit(3).Count()
+	if count != 3 || len(collector.Evaluations) == 0 {
+		t.Errorf("count %d, observed %v", count, collector.Evaluations)
+	}
+	clock := newFakeClock()
+	start := clock.Now()
+	count = New(createInts(10)).Sorted(less).WithClock(clock).Limit(3).Throttle(1, 1).Count()
+	if count != 3 || clock.Now().Sub(start) != 2*time.Second {
+		t.Errorf("count %d after %v on the fake clock", count, clock.Now().Sub(start))
+	}
+
+	sorted := Parallel(createInts(1000)).Sorted(less)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sorted.Count()
+	}()
+	if count := sorted.Limit(3).Count(); count != 3 {
+		t.Errorf("count %d, want 3", count)
+	}
+	<-done
+}
+
+func BenchmarkSortedLimit(b *testing.B) {
+	ints := createInts(100000)
+	less := func(i, j interface{}) bool {
+		return i.(int) < j.(int)
+	}
+	for i := 0; i < b.N; i++ {
+		New(ints).Sorted(less).Limit(10).Count()
+	}
+}
+
+func BenchmarkSortedFullLimit(b *testing.B) {
+	ints := createInts(100000)
+	less := func(i, j interface{}) bool {
+		return i.(int) < j.(int)
+	}
+	for i := 0; i < b.N; i++ {
+		New(ints).Sorted(less).Peek(func(v interface{}) {}).Limit(10).Count()
+	}
+}
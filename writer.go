@@ -0,0 +1,180 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// The writer terminals keep encounter order: a sequential stream writes
+// through a buffer as it goes, the ranges of a parallel stream encode into
+// buffers of their own, each written as soon as the ranges before it are. They
+// return the first encoding or write error, or else the error of the stream.
+
+// WriteLines writes every element formatted with %v on a line of its own.
+func (p *pipeline) WriteLines(w io.Writer) error {
+	return p.write("WriteLines", w, func(w io.Writer) encoder {
+		return &lineEncoder{w: w}
+	})
+}
+
+// WriteCSV writes every element, a []string, as a CSV record.
+func (p *pipeline) WriteCSV(w io.Writer) error {
+	return p.write("WriteCSV", w, func(w io.Writer) encoder {
+		return &csvEncoder{w: csv.NewWriter(w)}
+	})
+}
+
+// WriteJSONLines writes every element as JSON on a line of its own.
+func (p *pipeline) WriteJSONLines(w io.Writer) error {
+	return p.write("WriteJSONLines", w, func(w io.Writer) encoder {
+		return &jsonEncoder{e: json.NewEncoder(w)}
+	})
+}
+
+type encoder interface {
+	encode(v interface{}) error
+	flush() error
+}
+
+type lineEncoder struct {
+	w io.Writer
+}
+
+func (l *lineEncoder) encode(v interface{}) error {
+	_, err := fmt.Fprintln(l.w, v)
+	return err
+}
+func (l *lineEncoder) flush() error {
+	return nil
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (c *csvEncoder) encode(v interface{}) error {
+	record, ok := v.([]string)
+	if !ok {
+		return fmt.Errorf("csv record must be a []string, not %T", v)
+	}
+	return c.w.Write(record)
+}
+func (c *csvEncoder) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonEncoder struct {
+	e *json.Encoder
+}
+
+func (j *jsonEncoder) encode(v interface{}) error {
+	return j.e.Encode(v)
+}
+func (j *jsonEncoder) flush() error {
+	return nil
+}
+
+func (p *pipeline) write(name string, w io.Writer, newEncoder func(w io.Writer) encoder) error {
+	nilCheck(w)
+	var h *handoff
+	if p.sourceStage.parallel {
+		h = &handoff{w: w}
+		h.cond = sync.NewCond(&h.lock)
+	}
+	collected, err := p.collectErr(name, func() sink {
+		s := &writerSink{handoff: h}
+		if h != nil {
+			s.buffer = &bytes.Buffer{}
+			s.encoder = newEncoder(s.buffer)
+		} else {
+			s.writer = bufio.NewWriter(w)
+			s.encoder = newEncoder(s.writer)
+		}
+		return s
+	})
+	s := collected.(*writerSink)
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
+
+// writerSink encodes into writer, or into buffer when it is one range of a
+// parallel evaluation, which hands buffer off on end.
+type writerSink struct {
+	encoder encoder
+	writer  *bufio.Writer
+	buffer  *bytes.Buffer
+	handoff *handoff
+	span    *span
+	err     error
+}
+
+func (s *writerSink) setSpan(r *span) {
+	s.span = r
+}
+
+func (s *writerSink) accept(v interface{}) {
+	if s.err == nil {
+		s.err = s.encoder.encode(v)
+	}
+}
+func (s *writerSink) end() {
+	if err := s.encoder.flush(); s.err == nil {
+		s.err = err
+	}
+	if s.writer != nil {
+		if err := s.writer.Flush(); s.err == nil {
+			s.err = err
+		}
+	}
+	if s.handoff != nil {
+		s.err = s.handoff.write(s.span, s.buffer.Bytes(), s.err)
+		s.buffer = nil
+	}
+}
+func (s *writerSink) cancellationRequested() bool {
+	return s.err != nil
+}
+func (s *writerSink) combine(right sink) {
+	if s.err == nil {
+		s.err = right.(*writerSink).err
+	}
+}
+
+// handoff writes the buffers of the ranges of a parallel evaluation to w in
+// encounter order, next is the offset of the range whose turn it is. Once a
+// range failed the ranges after it are not written.
+type handoff struct {
+	lock sync.Mutex
+	cond *sync.Cond
+	w    io.Writer
+	next int
+	err  error
+}
+
+// write waits for the turn of r, writes data unless a range before failed and
+// returns the first error of the ranges up to r.
+func (h *handoff) write(r *span, data []byte, err error) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for h.next != r.offset {
+		h.cond.Wait()
+	}
+	if h.err == nil {
+		if _, werr := h.w.Write(data); werr != nil {
+			h.err = werr
+		} else {
+			h.err = err
+		}
+	}
+	h.next = r.offset + r.length
+	h.cond.Broadcast()
+	return h.err
+}
@@ -0,0 +1,108 @@
+package stream
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type failingWriter struct {
+	n int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if f.n < len(p) {
+		return f.n, errors.New("disk full")
+	}
+	f.n -= len(p)
+	return len(p), nil
+}
+
+// signalingWriter closes written on the first write.
+type signalingWriter struct {
+	strings.Builder
+	once    sync.Once
+	written chan struct{}
+}
+
+func (s *signalingWriter) Write(p []byte) (int, error) {
+	s.once.Do(func() {
+		close(s.written)
+	})
+	return s.Builder.Write(p)
+}
+
+func TestWriteLines(t *testing.T) {
+	ints := createInts(1000)
+	var want strings.Builder
+	for _, v := range ints {
+		want.WriteString(strconv.Itoa(v) + "\n")
+	}
+	for _, s := range []Stream{New(ints), Parallel(ints)} {
+		var b strings.Builder
+		if err := s.WriteLines(&b); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != want.String() {
+			t.Errorf("lines out of order")
+		}
+	}
+
+	if err := New(ints).WriteLines(&failingWriter{n: 100}); err == nil {
+		t.Error("no error")
+	}
+	if err := Parallel(ints).WriteLines(&failingWriter{n: 100}); err == nil {
+		t.Error("no error")
+	}
+
+	w := &signalingWriter{written: make(chan struct{})}
+	last := ints[len(ints)-1]
+	err := Parallel(ints).Peek(func(v interface{}) {
+		if v == last {
+			select {
+			case <-w.written:
+			case <-time.After(5 * time.Second):
+				t.Error("the first ranges were not written before the last one ended")
+			}
+		}
+	}).WriteLines(w)
+	if err != nil || w.String() != want.String() {
+		t.Errorf("err %v, lines out of order", err)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b strings.Builder
+	err := Parallel(createStudents()).Map(func(v interface{}) interface{} {
+		s := v.(student)
+		return []string{strconv.Itoa(s.id), s.name, strconv.Itoa(s.age)}
+	}).WriteCSV(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "1,") || strings.Count(b.String(), "\n") != 10 {
+		t.Errorf("csv %q", b.String())
+	}
+
+	if err := New(createInts(3)).WriteCSV(&b); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	type score struct {
+		Name  string
+		Score int
+	}
+	var b strings.Builder
+	err := New([]score{{"Tom", 80}, {"Kate", 95}}).WriteJSONLines(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "{\"Name\":\"Tom\",\"Score\":80}\n{\"Name\":\"Kate\",\"Score\":95}\n" {
+		t.Errorf("json %q", b.String())
+	}
+}